@@ -0,0 +1,119 @@
+package mdcalc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/eliiasg/mdcalc/config"
+	"github.com/eliiasg/mdcalc/diag"
+	"github.com/eliiasg/mdcalc/parse"
+	"github.com/eliiasg/mdcalc/unitlib"
+)
+
+// the cache directory in the project directory, with the latest rendering of every problem
+const CacheDirName = ".mdcalc-cache"
+
+// Removes the cache of the project in dir
+func CleanCache(dir string) error {
+	return os.RemoveAll(filepath.Join(dir, CacheDirName))
+}
+
+// a rendered problem, only used if the key is the key of the problem now
+type cacheEntry struct {
+	Key         string
+	Text        string
+	Answers     []parse.Answer
+	Assets      map[string][]byte
+	Diagnostics []diag.Diagnostic
+}
+
+// whether problems are cached, a custom formatter or unit library may render differently without changing any files
+func (p *Project) caching() bool {
+	return p.options.Cache && p.options.Formatter == nil && p.options.UnitLibrary == nil
+}
+
+// hash of everything the rendering of the problem depends on
+func (p *Project) cacheKey(problem Problem, src []byte, po parse.Options) string {
+	h := sha256.New()
+	write := func(data []byte) {
+		// the length first, so the parts can not be mixed up
+		fmt.Fprintf(h, "%v\n", len(data))
+		h.Write(data)
+	}
+	write([]byte(executableHash()))
+	write([]byte(fmt.Sprintf("%v %v %v %v %+v", problem.Name, po.Header, po.File, po.EmbedErrors, p.options.Locale)))
+	write(src)
+	files := append([]string{config.FileName, unitlib.NamesFileName, unitlib.OperationsFileName}, parse.IncludedFiles(string(src))...)
+	for _, name := range files {
+		// missing files are written as empty, which is fine since they are empty when missing
+		dat, _ := os.ReadFile(filepath.Join(p.Dir, name))
+		write([]byte(name))
+		write(dat)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *Project) cachePath(problem Problem) string {
+	return filepath.Join(p.Dir, CacheDirName, problem.Name+".json")
+}
+
+func (p *Project) loadCache(problem Problem, key string) (cacheEntry, bool) {
+	dat, err := os.ReadFile(p.cachePath(problem))
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(dat, &entry); err != nil || entry.Key != key {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// failing to write the cache is not an error, the problem is just rendered again next time
+func (p *Project) storeCache(problem Problem, entry cacheEntry) {
+	dat, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	path := p.cachePath(problem)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	// written to a temporary file first, so a build that is stopped can not leave half an entry
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, dat, 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+var (
+	executableOnce sync.Once
+	executableSum  string
+)
+
+// hash of the running program, so a new version of mdcalc does not use the cache of an old version
+func executableHash() string {
+	executableOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err == nil {
+			executableSum = hex.EncodeToString(h.Sum(nil))
+		}
+	})
+	return executableSum
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/eliiasg/mdcalc"
+	"github.com/eliiasg/mdcalc/diag"
+)
+
+// Terrible code, I know
+
+func main() {
+	only := flag.String("problem", "", "only build a single problem, like 3a, the result is written to Result-<problem>.md")
+	workers := flag.Int("workers", runtime.NumCPU(), "amount of problem files rendered at the same time")
+	noCache := flag.Bool("no-cache", false, "render every problem, instead of only the problems that changed since the last build")
+	flag.Parse()
+	args := flag.Args()
+	d := &diag.Collector{}
+	opts := []mdcalc.Option{mdcalc.WithWorkers(*workers), mdcalc.WithCache(!*noCache)}
+	// build is the default
+	if len(args) == 4 && args[0] == "build" {
+		args = args[1:]
+	}
+	switch {
+	case len(args) == 2 && args[0] == "check":
+		check(args[1], opts, d)
+	case len(args) == 2 && args[0] == "clean-cache":
+		if err := mdcalc.CleanCache(args[1]); err != nil {
+			d.Errorf("", 0, 0, "%v", err)
+		}
+	case len(args) == 4 && args[0] == "watch":
+		watch(args[1], args[2], args[3], *only, opts)
+		return
+	case len(args) == 3:
+		build(context.Background(), args[0], args[1], args[2], *only, opts, d)
+	default:
+		fmt.Println("must call with 3 param (dir, prob name, title), or check, clean-cache or watch followed by the params")
+		os.Exit(2)
+	}
+	d.Print(os.Stdout)
+	if d.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+func build(ctx context.Context, dir, probName, title, only string, opts []mdcalc.Option, d *diag.Collector) {
+	p, ok := open(dir, opts, d)
+	if !ok {
+		return
+	}
+	defer func() {
+		d.Diagnostics = append(d.Diagnostics, p.Diagnostics.Diagnostics...)
+	}()
+	path := dir + "/Result.md"
+	if only != "" {
+		if !p.Select(only) {
+			p.Diagnostics.Errorf("", 0, 0, "problem '%v' not found", only)
+			return
+		}
+		path = fmt.Sprintf("%v/Result-%v.md", dir, only)
+	}
+	os.Remove(path)
+	f, err := os.Create(path)
+	if err != nil {
+		p.Diagnostics.Errorf("", 0, 0, "%v", err)
+		return
+	}
+	defer f.Close()
+	if err := p.Build(ctx, f, probName, title); err != nil {
+		p.Diagnostics.Errorf("", 0, 0, "%v", err)
+	}
+}
+
+// checks every problem without writing the result, and also warns about unused variables
+func check(dir string, opts []mdcalc.Option, d *diag.Collector) {
+	p, ok := open(dir, opts, d)
+	if !ok {
+		return
+	}
+	p.Check(context.Background())
+	d.Diagnostics = append(d.Diagnostics, p.Diagnostics.Diagnostics...)
+}
+
+func open(dir string, opts []mdcalc.Option, d *diag.Collector) (*mdcalc.Project, bool) {
+	p, err := mdcalc.OpenProject(dir, opts...)
+	if err != nil {
+		// the diagnostic has the file the error is from
+		var dg diag.Diagnostic
+		if errors.As(err, &dg) {
+			d.Add(dg)
+		} else {
+			d.Errorf("", 0, 0, "%v", err)
+		}
+		return nil, false
+	}
+	return p, true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eliiasg/mdcalc"
+	"github.com/eliiasg/mdcalc/config"
+	"github.com/eliiasg/mdcalc/diag"
+)
+
+// how often the project directory is checked for changes
+const pollInterval = 500 * time.Millisecond
+
+// builds the project every time a file in it changes, until interrupted
+func watch(dir, probName, title, only string, opts []mdcalc.Option) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	last := ""
+	for {
+		if cur := snapshot(dir, opts); cur != last {
+			d := &diag.Collector{}
+			build(ctx, dir, probName, title, only, opts, d)
+			if ctx.Err() != nil {
+				return
+			}
+			d.Print(os.Stdout)
+			fmt.Printf("built at %v, watching for changes\n", time.Now().Format("15:04:05"))
+			// the build writes the result and maybe the unit library, which should not start another build
+			last = snapshot(dir, opts)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// the name, size and modification time of every file the build depends on, problem files can be in subdirectories
+// and loaded files in other directories. If the project can not be opened the error is used instead, so fixing it starts a build
+func snapshot(dir string, opts []mdcalc.Option) string {
+	p, err := mdcalc.OpenProject(dir, opts...)
+	if err != nil {
+		// the config is still watched, since that is usually where the error is
+		return err.Error() + "\n" + stamp(filepath.Join(dir, config.FileName))
+	}
+	var sb strings.Builder
+	for _, path := range p.Files() {
+		sb.WriteString(stamp(path))
+	}
+	return sb.String()
+}
+
+// empty for files that do not exist
+func stamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%v %v %v\n", path, info.Size(), info.ModTime().UnixNano())
+}
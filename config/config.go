@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+const FileName = "mdcalc.txt"
+
+type Config struct {
+	// File names or globs, matched files are rendered in natural order, explicitly named files in the order listed
+	Problems []string
+	// write errors into the output instead of leaving out the lines with errors
+	EmbedErrors bool
+	// add a table of every answer at the end of the document
+	AnswerTable bool
+	Settings    syntax.Settings
+}
+
+// Loads the project config from dir, a missing config file results in the default config
+func Load(dir string) (*Config, error) {
+	cfg := &Config{EmbedErrors: true, Settings: syntax.DefaultSettings()}
+	bytes, err := os.ReadFile(dir + "/" + FileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	for i, line := range strings.Split(string(bytes), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		dat := strings.Fields(line)
+		if len(dat) < 2 {
+			return nil, fmt.Errorf("line %v of config file is invalid", i+1)
+		}
+		switch dat[0] {
+		case "problems":
+			cfg.Problems = append(cfg.Problems, dat[1:]...)
+		case "errors":
+			switch dat[1] {
+			case "embed":
+				cfg.EmbedErrors = true
+			case "omit":
+				cfg.EmbedErrors = false
+			default:
+				return nil, fmt.Errorf("line %v of config file: errors must be either embed or omit", i+1)
+			}
+		case "answers":
+			if dat[1] != "table" {
+				return nil, fmt.Errorf("line %v of config file: answers must be table", i+1)
+			}
+			cfg.AnswerTable = true
+		default:
+			if err := cfg.Settings.Set(dat[0], dat[1]); err != nil {
+				return nil, fmt.Errorf("line %v of config file: %v", i+1, err.Error())
+			}
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,97 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+type Diagnostic struct {
+	Severity Severity
+	File     string
+	// Line and Column start at 1, 0 if unknown
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += fmt.Sprintf(":%v", d.Line)
+		if d.Column > 0 {
+			pos += fmt.Sprintf(":%v", d.Column)
+		}
+	}
+	if pos == "" {
+		return fmt.Sprintf("%v: %v", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%v: %v: %v", pos, d.Severity, d.Message)
+}
+
+// so a single diagnostic can be returned as an error
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// Collects errors and warnings across all files of a project, in the order they are found
+type Collector struct {
+	Diagnostics []Diagnostic
+}
+
+func (c *Collector) Add(d Diagnostic) {
+	c.Diagnostics = append(c.Diagnostics, d)
+}
+
+func (c *Collector) Errorf(file string, line, col int, format string, args ...any) {
+	c.Add(Diagnostic{Severity: Error, File: file, Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Collector) Warnf(file string, line, col int, format string, args ...any) {
+	c.Add(Diagnostic{Severity: Warning, File: file, Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *Collector) Count(s Severity) int {
+	n := 0
+	for _, d := range c.Diagnostics {
+		if d.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Collector) HasErrors() bool {
+	return c.Count(Error) > 0
+}
+
+// Prints every diagnostic followed by a summary line, prints nothing if there are no diagnostics
+func (c *Collector) Print(w io.Writer) {
+	if len(c.Diagnostics) == 0 {
+		return
+	}
+	for _, d := range c.Diagnostics {
+		fmt.Fprintln(w, d.String())
+	}
+	fmt.Fprintf(w, "%v, %v\n", plural(c.Count(Error), "error"), plural(c.Count(Warning), "warning"))
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%v %v", n, word)
+	}
+	return fmt.Sprintf("%v %vs", n, word)
+}
@@ -0,0 +1,195 @@
+// Package mdcalc renders mdcalc problem files to markdown, where every calculation is written out step by step in LaTeX.
+//
+// Evaluate calculates a single expression, RenderFile renders the source of a single problem file,
+// and OpenProject loads a project directory that can be built to an io.Writer.
+// Everything can be configured with the With... options.
+package mdcalc
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/eliiasg/mdcalc/diag"
+	"github.com/eliiasg/mdcalc/parse"
+	"github.com/eliiasg/mdcalc/setup"
+	"github.com/eliiasg/mdcalc/syntax"
+	"github.com/eliiasg/mdcalc/unitlib"
+)
+
+// How expressions and files are rendered, set with the With... options
+type Options struct {
+	// used instead of the default formatter when set
+	Formatter syntax.Formatter
+	// nil for the default, which is the units.txt and operators.txt files of a project,
+	// and units written as they are for Evaluate and RenderFile
+	UnitLibrary syntax.UnitLibrary
+	Locale      setup.Locale
+	// the settings of a project are read from its config instead
+	Settings syntax.Settings
+	// where RenderFile loads the files of L lines from, "" is the working directory. Projects use their directory
+	Dir string
+	// write errors into the output, otherwise lines with errors are left out
+	EmbedErrors bool
+	// problems of projects are only rendered again when they or the files they depend on change,
+	// ignored when a formatter or unit library is set
+	Cache bool
+	// amount of problem files that are rendered at the same time, the formatter and unit library must be safe to use from that many goroutines
+	Workers int
+}
+
+// Changes one of the options, like WithLocale(setup.English)
+type Option func(*Options)
+
+// Replaces the default LaTeX formatter, projects are not cached when it is set
+func WithFormatter(f syntax.Formatter) Option {
+	return func(o *Options) {
+		o.Formatter = f
+	}
+}
+
+// The unit library decides the display names of units, and the units of operators on units
+func WithUnitLibrary(lib syntax.UnitLibrary) Option {
+	return func(o *Options) {
+		o.UnitLibrary = lib
+	}
+}
+
+// Like setup.English, defaults to setup.Danish
+func WithLocale(locale setup.Locale) Option {
+	return func(o *Options) {
+		o.Locale = locale
+	}
+}
+
+// Used by Evaluate and RenderFile, and ignored by OpenProject and Build, which use the settings in the mdcalc.txt of the project
+func WithSettings(settings syntax.Settings) Option {
+	return func(o *Options) {
+		o.Settings = settings
+	}
+}
+
+// The directory L lines in RenderFile load files from, like the directory the source was read from
+func WithDir(dir string) Option {
+	return func(o *Options) {
+		o.Dir = dir
+	}
+}
+
+// Defaults to false, projects embed errors unless their mdcalc.txt has errors omit
+func WithEmbeddedErrors(embed bool) Option {
+	return func(o *Options) {
+		o.EmbedErrors = embed
+	}
+}
+
+// Defaults to false, the cache is stored in the project directory
+func WithCache(cache bool) Option {
+	return func(o *Options) {
+		o.Cache = cache
+	}
+}
+
+// Defaults to the amount of CPUs, 1 renders one problem at a time
+func WithWorkers(n int) Option {
+	return func(o *Options) {
+		o.Workers = n
+	}
+}
+
+func newOptions(opts []Option) Options {
+	res := Options{
+		Locale:   setup.Danish,
+		Settings: syntax.DefaultSettings(),
+		Workers:  runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(&res)
+	}
+	res.Workers = max(res.Workers, 1)
+	return res
+}
+
+func (o Options) unitLibrary() syntax.UnitLibrary {
+	if o.UnitLibrary == nil {
+		return &unitlib.SimpleUnitLibrary{}
+	}
+	return o.UnitLibrary
+}
+
+func (o Options) environment() *syntax.Environment {
+	env := setup.NewEnvironment(o.unitLibrary(), o.Locale)
+	env.Settings = o.Settings
+	if o.Formatter != nil {
+		env.Formatter = o.Formatter
+	}
+	return env
+}
+
+// Calculates a single expression like 2*sqrt(16), the result has the value and unit of the expression.
+// Long calculations like solve and integrate stop with the error of ctx when it is done
+func Evaluate(ctx context.Context, expr string, opts ...Option) (syntax.VariableValue, error) {
+	if err := ctx.Err(); err != nil {
+		return syntax.VariableValue{}, err
+	}
+	env := newOptions(opts).environment()
+	env.Context = ctx
+	res, err := env.Calculate(expr)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return syntax.VariableValue{}, ctxErr
+	}
+	return res, err
+}
+
+// A rendered problem file
+type Document struct {
+	// the name of the problem, like 3a
+	Name string
+	// markdown with the calculations as LaTeX
+	Text    string
+	Answers []parse.Answer
+	// files that are referenced by the text, like plots, by name
+	Assets map[string][]byte
+	// errors and warnings, the text should not be used if there are errors
+	Diagnostics []diag.Diagnostic
+}
+
+// Whether any of the diagnostics is an error
+func (d *Document) HasErrors() bool {
+	for _, dg := range d.Diagnostics {
+		if dg.Severity == diag.Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Renders the source of a problem file, name is the problem name like 3a.
+// The error is only set if ctx is done, errors in the source are in the diagnostics of the document
+func RenderFile(ctx context.Context, name, src string, opts ...Option) (*Document, error) {
+	o := newOptions(opts)
+	d := &diag.Collector{}
+	out := renderProblem(ctx, src, parse.Options{
+		Header:      name,
+		Sub:         name + ".<n>",
+		File:        name + ".mdc",
+		Dir:         o.Dir,
+		Settings:    o.Settings,
+		EmbedErrors: o.EmbedErrors,
+	}, o, o.unitLibrary(), d)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &Document{
+		Name:        name,
+		Text:        out.Text,
+		Answers:     out.Answers,
+		Assets:      out.Assets,
+		Diagnostics: d.Diagnostics,
+	}, nil
+}
+
+// parses src with the locale and formatter of o
+func renderProblem(ctx context.Context, src string, po parse.Options, o Options, lib syntax.UnitLibrary, d *diag.Collector) parse.Output {
+	po.Locale, po.Formatter = o.Locale, o.Formatter
+	return parse.Parse(ctx, src, po, lib, d)
+}
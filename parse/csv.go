@@ -0,0 +1,120 @@
+package parse
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// The files loaded by the L lines of mdc, relative to the project directory
+func IncludedFiles(mdc string) []string {
+	res := make([]string, 0)
+	lines := strings.Split(mdc, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// lines in text blocks are text, even when they start with L
+		if strings.HasPrefix(line, textBlockStart) {
+			i = textBlockEnd(lines, i+1)
+			continue
+		}
+		if !strings.HasPrefix(line, "L") {
+			continue
+		}
+		if args := strings.Fields(line[1:]); len(args) >= 2 {
+			res = append(res, args[1])
+		}
+	}
+	return res
+}
+
+// L data observations.csv [column]
+// column is either the header or the number of the column starting at 1, defaults to the first column with numbers
+func loadData(content, dir string) (string, syntax.List, error) {
+	args := strings.Fields(content)
+	if len(args) < 2 || len(args) > 3 {
+		return "", nil, errors.New("data must be loaded like L data observations.csv column")
+	}
+	bytes, err := os.ReadFile(filepath.Join(dir, args[1]))
+	if err != nil {
+		return "", nil, fmt.Errorf("could not read '%v'", args[1])
+	}
+	text := string(bytes)
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	// Danish spreadsheets use ; between columns, since , is the decimal separator
+	if strings.Contains(strings.SplitN(text, "\n", 2)[0], ";") {
+		r.Comma = ';'
+	}
+	rows, err := r.ReadAll()
+	if err != nil {
+		return "", nil, fmt.Errorf("error in '%v': %v", args[1], err.Error())
+	}
+	if len(rows) == 0 {
+		return "", nil, fmt.Errorf("'%v' is empty", args[1])
+	}
+	header := false
+	for _, cell := range rows[0] {
+		if _, ok := parseCell(cell); !ok && strings.TrimSpace(cell) != "" {
+			header = true
+		}
+	}
+	col, err := dataColumn(rows, header, args[2:])
+	if err != nil {
+		return "", nil, fmt.Errorf("error in '%v': %v", args[1], err.Error())
+	}
+	if header {
+		rows = rows[1:]
+	}
+	res := make(syntax.List, 0, len(rows))
+	for i, row := range rows {
+		if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+			continue
+		}
+		n, ok := parseCell(row[col])
+		if !ok {
+			return "", nil, fmt.Errorf("error in '%v': '%v' in row %v is not a number", args[1], row[col], i+1)
+		}
+		res = append(res, n)
+	}
+	return args[0], res, nil
+}
+
+func dataColumn(rows [][]string, header bool, arg []string) (int, error) {
+	if len(arg) == 0 {
+		start := 0
+		if header {
+			start = 1
+		}
+		for i := range rows[0] {
+			if start < len(rows) && i < len(rows[start]) {
+				if _, ok := parseCell(rows[start][i]); ok {
+					return i, nil
+				}
+			}
+		}
+		return -1, errors.New("no column with numbers")
+	}
+	if header {
+		for i, cell := range rows[0] {
+			if strings.TrimSpace(cell) == arg[0] {
+				return i, nil
+			}
+		}
+	}
+	n, err := strconv.Atoi(arg[0])
+	if err != nil || n < 1 {
+		return -1, fmt.Errorf("column '%v' not found", arg[0])
+	}
+	return n - 1, nil
+}
+
+func parseCell(cell string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(cell), ",", "."), 64)
+	return n, err == nil
+}
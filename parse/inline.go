@@ -0,0 +1,104 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+type inlineError struct {
+	// byte offset of the { in the text
+	Offset int
+	Err    error
+	// braces that are not a calculation, like {1, 2}, are left as written with a warning
+	Warning bool
+}
+
+// replaces {expr} and {expr:precision} in text with the formatted result, \{ is a literal {
+// and math in $...$ or $$...$$ is left as it is, since braces are part of LaTeX there.
+// calculations that fail are left as written, or replaced by the error if embedErrors is set
+func interpolate(text string, env *syntax.Environment, embedErrors bool) (string, []inlineError) {
+	var sb strings.Builder
+	errs := make([]inlineError, 0)
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		if c == '\\' && i+1 < len(text) && (text[i+1] == '{' || text[i+1] == '}') {
+			sb.WriteByte(text[i+1])
+			i += 2
+			continue
+		}
+		if c == '$' {
+			if end := mathEnd(text, i); end != -1 {
+				sb.WriteString(text[i:end])
+				i = end
+				continue
+			}
+		}
+		if c != '{' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		end := strings.IndexByte(text[i:], '}')
+		if end == -1 {
+			errs = append(errs, inlineError{i, fmt.Errorf("missing } for inline calculation"), false})
+			sb.WriteString(text[i:])
+			break
+		}
+		code, precision := inlinePrecision(text[i+1 : i+end])
+		if _, err := syntax.GenerateAst(syntax.Tokenize(code)); err != nil {
+			errs = append(errs, inlineError{i, fmt.Errorf("%v is not a calculation and is left as written, use \\{ for a literal {", text[i:i+end+1]), true})
+			sb.WriteString(text[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		res, err := env.MakeInlineCalculation(code, precision)
+		if err != nil {
+			errs = append(errs, inlineError{i, err, false})
+			if embedErrors {
+				res = fmt.Sprintf("<span style=\"color:red\">Error: %v</span>", err.Error())
+			} else {
+				res = text[i : i+end+1]
+			}
+		}
+		sb.WriteString(res)
+		i += end + 1
+	}
+	return sb.String(), errs
+}
+
+// the index after the math starting at start, -1 if the math is not closed
+func mathEnd(text string, start int) int {
+	delim := "$"
+	if strings.HasPrefix(text[start:], "$$") {
+		delim = "$$"
+	}
+	from := start + len(delim)
+	for i := from; i < len(text); i++ {
+		if text[i] == '\\' {
+			// skips \$ and \\
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], delim) {
+			return i + len(delim)
+		}
+	}
+	return -1
+}
+
+// {l:2} renders l with 2 decimals, without a precision 2 decimals are used like for calculations
+func inlinePrecision(code string) (string, int) {
+	split := strings.LastIndex(code, ":")
+	if split == -1 {
+		return code, 2
+	}
+	p, err := strconv.ParseInt(strings.TrimSpace(code[split+1:]), 10, 32)
+	if err != nil {
+		return code, 2
+	}
+	return code[:split], int(p)
+}
@@ -1,22 +1,95 @@
 package parse
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
+	"github.com/eliiasg/mdcalc/diag"
 	"github.com/eliiasg/mdcalc/setup"
 	"github.com/eliiasg/mdcalc/syntax"
 )
 
+type Options struct {
+	// title of the problem
+	Header string
+	// subproblem name where <n> will be replaced by the index
+	Sub string
+	// file name used for diagnostics
+	File string
+	// project directory, data files are loaded relative to this
+	Dir      string
+	Settings syntax.Settings
+	// write errors into the output, otherwise lines with errors are left out
+	EmbedErrors bool
+	// warn about variables that are set but never used, for mdcalc check
+	ReportUnused bool
+	// Danish if not set
+	Locale setup.Locale
+	// used instead of the default formatter when set
+	Formatter syntax.Formatter
+}
+
+type Output struct {
+	Text    string
+	Answers []Answer
+	// files that should be written next to the output, like plots, by name
+	Assets map[string][]byte
+}
+
+// Result of a calculation marked with C!
+type Answer struct {
+	// name of the subproblem, or the header if before the first subproblem
+	Sub string
+	// comment of the calculation, or the text line before it
+	Description string
+	// formatted value and unit display name
+	Value string
+	Unit  string
+}
+
 // Terrible code
-// parse mdcalc code: mdc is the code to be parsed, header is the title, and sub is the subproblem name where <n> will be replaced by the index
-func Parse(mdc, header, sub string, lib syntax.UnitLibrary) (string, error) {
-	env := setup.GenerateEnvironment(lib)
+// parse mdcalc code, every error and warning is added to d, so the output should not be used if d has errors.
+// Parsing stops with an error when ctx is done, also in the middle of long calculations
+func Parse(ctx context.Context, mdc string, opts Options, lib syntax.UnitLibrary, d *diag.Collector) Output {
+	if opts.Locale == (setup.Locale{}) {
+		opts.Locale = setup.Danish
+	}
+	env := setup.NewEnvironment(lib, opts.Locale)
+	env.Settings = opts.Settings
+	env.Context = ctx
+	if opts.Formatter != nil {
+		env.Formatter = opts.Formatter
+	}
 	var sb strings.Builder
+	answers := make([]Answer, 0)
+	assets := make(map[string][]byte)
 	started := false
 	n := 1
-	for i, line := range strings.Split(mdc, "\n") {
-		sb.WriteString("  \n")
+	sub := opts.Header
+	lastText := ""
+	// variables set in a subproblem with its own scope are only used in that subproblem,
+	// other variables can be used anywhere later in the file
+	var fileDefs, scopeDefs definitions
+	scoped := false
+	define := func(name string, line, col int, exported bool) {
+		if scoped && !exported {
+			scopeDefs.add(name, line, col)
+		} else {
+			fileDefs.add(name, line, col)
+		}
+	}
+	prev := blockNone
+	lines := strings.Split(mdc, "\n")
+	for i := 0; i < len(lines); i++ {
+		if err := ctx.Err(); err != nil {
+			d.Errorf(opts.File, i+1, 0, "%v", err)
+			break
+		}
+		line := lines[i]
+		cur := markdownBlock(line)
+		sb.WriteString(separator(prev, cur))
+		prev = cur
 		if len(line) == 0 {
 			continue
 		}
@@ -28,36 +101,253 @@ func Parse(mdc, header, sub string, lib syntax.UnitLibrary) (string, error) {
 		}
 		switch line[0] {
 		default:
-			return "", err(i, "Every line must start with either T, C or |")
+			d.Errorf(opts.File, i+1, 1, "every line must start with either T, C, I, P, L, S or |, got '%v'", string(line[0]))
 		case '|':
 			if !started {
 				sb.Reset()
 				sb.WriteString("# ")
-				sb.WriteString(header + "\n")
+				sb.WriteString(opts.Header + "\n")
 				started = true
 			}
+			if env.Settings.ScopedSubproblems {
+				if opts.ReportUnused {
+					scopeDefs.report(d, env, opts.File)
+				}
+				scopeDefs = definitions{}
+				env.EnterScope()
+				scoped = true
+			}
+			sub = strings.ReplaceAll(opts.Sub, "<n>", fmt.Sprint(n))
 			sb.WriteString("### ")
-			sb.WriteString(strings.ReplaceAll(sub, "<n>", fmt.Sprint(n)))
+			sb.WriteString(sub)
+			lastText = ""
 			n++
 		case 'T':
+			if strings.HasPrefix(line, textBlockStart) {
+				end := textBlockEnd(lines, i+1)
+				if end == len(lines) {
+					d.Errorf(opts.File, i+1, 1, "text block is never closed, expected a line with %v", textBlockStop)
+				}
+				block := lines[i+1 : end]
+				// text on the same line as T>>> is the first line of the block
+				if first := strings.TrimSpace(line[len(textBlockStart):]); first != "" {
+					block = append([]string{first}, block...)
+				}
+				sb.WriteString(strings.Join(block, "\n"))
+				i = end
+				continue
+			}
 			if len(line) < 3 {
-				return "", err(i, "Text lines must start with a T followed by a space followed by text")
+				d.Errorf(opts.File, i+1, 1, "text lines must start with a T followed by a space followed by text")
+				continue
+			}
+			// the column of inline calculations is known, other errors are reported where the content of the line starts
+			col := contentColumn(line)
+			if cur != blockNone {
+				// keep indentation for nested lists
+				content = strings.TrimRight(line[2:], " \t")
+				col = 3
+			}
+			text, errs := interpolate(content, env, opts.EmbedErrors)
+			for _, e := range errs {
+				if e.Warning {
+					d.Warnf(opts.File, i+1, col+e.Offset, "%v", e.Err.Error())
+				} else {
+					d.Errorf(opts.File, i+1, col+e.Offset, "%v", e.Err.Error())
+				}
+			}
+			sb.WriteString(text)
+			lastText = text
+		case 'S':
+			// settings that only apply to the rest of this file, like S angles rad
+			args := strings.Fields(content)
+			if len(args) != 2 {
+				d.Errorf(opts.File, i+1, contentColumn(line), "settings must be written like S angles rad")
+				continue
+			}
+			if err := env.Settings.Set(args[0], args[1]); err != nil {
+				d.Errorf(opts.File, i+1, contentColumn(line), "%v", err.Error())
 			}
-			sb.WriteString(content)
 		case 'I':
 			sb.WriteString(fmt.Sprintf("![Image!](%v)", content))
+		case 'L':
+			name, data, err := loadData(content, opts.Dir)
+			if err == nil {
+				var res syntax.Result
+				res, err = env.WriteVariable(name, syntax.VariableValue{Value: data}, &sb)
+				warn(d, res, opts.File, i+1, contentColumn(line))
+			}
+			define(name, i+1, contentColumn(line), false)
+			if err != nil {
+				d.Errorf(opts.File, i+1, contentColumn(line), "%v", err.Error())
+				if opts.EmbedErrors {
+					sb.WriteString(fmt.Sprintf("### <span style=\"color:red\">Error: %v</span>", err.Error()))
+				}
+			}
+		case 'P':
+			p, err := makePlot(content, env)
+			if err != nil {
+				d.Errorf(opts.File, i+1, contentColumn(line), "%v", err.Error())
+				if opts.EmbedErrors {
+					sb.WriteString(fmt.Sprintf("### <span style=\"color:red\">Error: %v</span>", err.Error()))
+				}
+				continue
+			}
+			name := fmt.Sprintf("plot-%v-%v.svg", strings.TrimSuffix(opts.File, ".mdc"), len(assets)+1)
+			assets[name] = p.SVG()
+			sb.WriteString(fmt.Sprintf("![%v](%v)", p.Title, name))
 		case 'C':
-			err := env.WriteCalculation(content, &sb)
+			res, err := env.WriteCalculation(content, &sb)
+			warn(d, res, opts.File, i+1, contentColumn(line))
 			if err != nil {
-				fmt.Printf("error on line %v:\n", i+1)
-				sb.WriteString(fmt.Sprintf("### <span style=\"color:red\">Error: %v</span>", err.Error()))
-				fmt.Println(err.Error())
+				d.Errorf(opts.File, i+1, contentColumn(line), "%v", err.Error())
+				if opts.EmbedErrors {
+					sb.WriteString(fmt.Sprintf("### <span style=\"color:red\">Error: %v</span>", err.Error()))
+				}
+				continue
 			}
+			// answers are used by being the answer
+			if len(line) < 2 || line[1] != '!' {
+				define(res.Name, i+1, contentColumn(line), strings.HasPrefix(content, "export "))
+				continue
+			}
+			answer := makeAnswer(env, res, sub, lastText)
+			sb.WriteString(fmt.Sprintf("\n$$\n\\boxed{\\text{%v: }%v}\n$$", opts.Locale.Answer, env.Formatter.FormatNumber(res.Value, res.Precision, answer.Unit, "")))
+			answers = append(answers, answer)
+		}
+	}
+	if opts.ReportUnused {
+		scopeDefs.report(d, env, opts.File)
+		fileDefs.report(d, env, opts.File)
+	}
+	return Output{Text: sb.String(), Answers: answers, Assets: assets}
+}
+
+// where variables are first set, for finding unused variables
+type definitions struct {
+	// line and column
+	positions map[string][2]int
+	names     []string
+}
+
+func (defs *definitions) add(name string, line, col int) {
+	if defs.positions == nil {
+		defs.positions = make(map[string][2]int)
+	}
+	if _, ok := defs.positions[name]; !ok && name != "" {
+		defs.positions[name] = [2]int{line, col}
+		defs.names = append(defs.names, name)
+	}
+}
+
+// warns about the variables that have not been used
+func (defs *definitions) report(d *diag.Collector, env *syntax.Environment, file string) {
+	for _, name := range defs.names {
+		if !env.Used(name) {
+			pos := defs.positions[name]
+			d.Warnf(file, pos[0], pos[1], "'%v' is set but never used", name)
 		}
 	}
-	return sb.String(), nil
 }
 
-func err(ln int, msg string) error {
-	return fmt.Errorf("error on line %v: %v", ln+1, msg)
+// adds the warnings of the line to d, col is where the content of the line starts
+func warn(d *diag.Collector, res syntax.Result, file string, line, col int) {
+	for _, w := range res.Warnings {
+		d.Warnf(file, line, col, "%v", w)
+	}
+}
+
+func makeAnswer(env *syntax.Environment, res syntax.Result, sub, lastText string) Answer {
+	desc := strings.TrimSpace(res.Comment)
+	if desc == "" {
+		desc = strings.TrimSuffix(strings.TrimSpace(lastText), ":")
+	}
+	return Answer{
+		Sub:         sub,
+		Description: desc,
+		Value:       env.Formatter.FormatNumber(res.Value, res.Precision, "", ""),
+		Unit:        env.UnitLibrary.GetUnitDisplayName(res.Unit),
+	}
+}
+
+// Markdown table of answers from every problem, with a heading
+func AnswerTable(answers []Answer, locale setup.Locale) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %v\n\n", locale.Answer))
+	sb.WriteString(fmt.Sprintf("| %v |\n| - | - | - | - |", strings.Join(locale.AnswerTable[:], " | ")))
+	for _, a := range answers {
+		sb.WriteString(fmt.Sprintf("\n| %v | %v | $%v$ | %v |", a.Sub, escapeCell(a.Description), a.Value, escapeCell(a.Unit)))
+	}
+	return sb.String()
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// column of the first character after the instruction
+func contentColumn(line string) int {
+	if len(line) < 2 {
+		return 2
+	}
+	return 3 + len(line[2:]) - len(strings.TrimLeft(line[2:], " \t"))
+}
+
+const (
+	textBlockStart = "T>>>"
+	textBlockStop  = "<<<"
+)
+
+type block int
+
+const (
+	blockNone block = iota
+	blockList
+	blockTable
+	blockText
+)
+
+// Markdown lists and tables must not have hard line breaks between their lines, and must be surrounded by blank lines
+func separator(prev, cur block) string {
+	if prev == cur && (cur == blockList || cur == blockTable) {
+		return "\n"
+	}
+	if prev != blockNone || cur != blockNone {
+		return "\n\n"
+	}
+	return "  \n"
+}
+
+func markdownBlock(line string) block {
+	if strings.HasPrefix(line, textBlockStart) {
+		return blockText
+	}
+	if len(line) < 3 || line[0] != 'T' {
+		return blockNone
+	}
+	text := strings.TrimSpace(line[2:])
+	if strings.HasPrefix(text, "|") {
+		return blockTable
+	}
+	if strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "* ") || strings.HasPrefix(text, "+ ") {
+		return blockList
+	}
+	i := 0
+	for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+		i++
+	}
+	if i > 0 && (strings.HasPrefix(text[i:], ". ") || strings.HasPrefix(text[i:], ") ")) {
+		return blockList
+	}
+	return blockNone
+}
+
+// index of the line closing the text block starting at start, or len(lines) if it is never closed
+func textBlockEnd(lines []string, start int) int {
+	for i := start; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == textBlockStop {
+			return i
+		}
+	}
+	return len(lines)
 }
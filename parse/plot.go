@@ -0,0 +1,112 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/plot"
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+const plotSamples = 400
+
+// P f(x); g(x), x=-5..5, title, x1, x2
+// plots every expression separated by ; over the range, and marks the points at x1 and x2 on the first expression
+func makePlot(content string, env *syntax.Environment) (*plot.Plot, error) {
+	parts := splitTopLevel(content, ',')
+	if len(parts) < 2 {
+		return nil, errors.New("plots must be written like P f(x), x=-5..5, title")
+	}
+	variable, from, to, err := plotRange(parts[1], env)
+	if err != nil {
+		return nil, err
+	}
+	xs := make([]float64, plotSamples)
+	for i := range xs {
+		xs[i] = from + (to-from)*float64(i)/float64(plotSamples-1)
+	}
+	p := &plot.Plot{}
+	if len(parts) > 2 {
+		p.Title = strings.TrimSpace(parts[2])
+	}
+	exprs := splitTopLevel(parts[0], ';')
+	for _, expr := range exprs {
+		expr = strings.TrimSpace(expr)
+		ys, err := env.Sample(expr, variable, xs)
+		if err != nil {
+			return nil, fmt.Errorf("error while plotting '%v': %v", expr, err.Error())
+		}
+		p.Series = append(p.Series, plot.Series{Label: expr, X: xs, Y: ys})
+	}
+	for _, mark := range parts[min(3, len(parts)):] {
+		x, err := plotBound(mark, env)
+		if err != nil {
+			return nil, fmt.Errorf("error in plot point '%v': %v", strings.TrimSpace(mark), err.Error())
+		}
+		y, err := env.Sample(exprs[0], variable, []float64{x})
+		if err != nil {
+			return nil, fmt.Errorf("error in plot point '%v': %v", strings.TrimSpace(mark), err.Error())
+		}
+		p.Points = append(p.Points, plot.Point{X: x, Y: y[0]})
+	}
+	return p, nil
+}
+
+// x=-5..5
+func plotRange(code string, env *syntax.Environment) (string, float64, float64, error) {
+	eq := strings.Index(code, "=")
+	dots := strings.Index(code, "..")
+	if eq == -1 || dots < eq {
+		return "", 0, 0, fmt.Errorf("plot range must be written like x=-5..5, got '%v'", strings.TrimSpace(code))
+	}
+	variable := strings.TrimSpace(code[:eq])
+	from, err := plotBound(code[eq+1:dots], env)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	to, err := plotBound(code[dots+2:], env)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if !(from < to) || math.IsInf(to-from, 0) {
+		return "", 0, 0, fmt.Errorf("invalid plot range %v..%v", from, to)
+	}
+	return variable, from, to, nil
+}
+
+// plain numbers (which may be negative) or expressions
+func plotBound(code string, env *syntax.Environment) (float64, error) {
+	code = strings.TrimSpace(code)
+	if v, err := strconv.ParseFloat(code, 64); err == nil {
+		return v, nil
+	}
+	res, err := env.Calculate(code)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return syntax.Number(res.Value)
+}
+
+// splits at sep, except inside parenthesis
+func splitTopLevel(s string, sep rune) []string {
+	res := make([]string, 0)
+	depth := 0
+	start := 0
+	for i, c := range s {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		case sep:
+			if depth == 0 {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
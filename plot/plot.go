@@ -0,0 +1,200 @@
+package plot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	width   = 640
+	height  = 400
+	margin  = 50
+	fontCSS = "font-family:sans-serif;font-size:12px"
+)
+
+var colors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b"}
+
+// Sampled function, NaN and infinite values break the line
+type Series struct {
+	Label string
+	X, Y  []float64
+}
+
+// Marked point, like an intersection or extremum
+type Point struct {
+	Label string
+	X, Y  float64
+}
+
+type Plot struct {
+	Title  string
+	Series []Series
+	Points []Point
+}
+
+// Renders the plot as a standalone svg file
+func (p *Plot) SVG() []byte {
+	xMin, xMax, yMin, yMax := p.bounds()
+	sx := func(x float64) float64 {
+		return margin + (x-xMin)/(xMax-xMin)*(width-2*margin)
+	}
+	sy := func(y float64) float64 {
+		return height - margin - (y-yMin)/(yMax-yMin)*(height-2*margin)
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" viewBox=\"0 0 %v %v\">\n", width, height, width, height))
+	sb.WriteString(fmt.Sprintf("<rect width=\"%v\" height=\"%v\" fill=\"white\"/>\n", width, height))
+	sb.WriteString(fmt.Sprintf("<clipPath id=\"area\"><rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\"/></clipPath>\n", margin, margin, width-2*margin, height-2*margin))
+	if p.Title != "" {
+		sb.WriteString(fmt.Sprintf("<text x=\"%v\" y=\"%v\" text-anchor=\"middle\" style=\"font-family:sans-serif;font-size:16px\">%v</text>\n", width/2, margin/2+5, escape(p.Title)))
+	}
+	// grid and tick labels
+	for _, x := range ticks(xMin, xMax) {
+		sb.WriteString(fmt.Sprintf("<line x1=\"%.2f\" y1=\"%v\" x2=\"%.2f\" y2=\"%v\" stroke=\"#e0e0e0\"/>\n", sx(x), margin, sx(x), height-margin))
+		sb.WriteString(fmt.Sprintf("<text x=\"%.2f\" y=\"%v\" text-anchor=\"middle\" style=\"%v\">%v</text>\n", sx(x), height-margin+16, fontCSS, formatTick(x)))
+	}
+	for _, y := range ticks(yMin, yMax) {
+		sb.WriteString(fmt.Sprintf("<line x1=\"%v\" y1=\"%.2f\" x2=\"%v\" y2=\"%.2f\" stroke=\"#e0e0e0\"/>\n", margin, sy(y), width-margin, sy(y)))
+		sb.WriteString(fmt.Sprintf("<text x=\"%v\" y=\"%.2f\" text-anchor=\"end\" style=\"%v\">%v</text>\n", margin-6, sy(y)+4, fontCSS, formatTick(y)))
+	}
+	// axes through 0 when visible, otherwise along the edge
+	ax := sx(math.Max(xMin, math.Min(0, xMax)))
+	ay := sy(math.Max(yMin, math.Min(0, yMax)))
+	sb.WriteString(fmt.Sprintf("<line x1=\"%v\" y1=\"%.2f\" x2=\"%v\" y2=\"%.2f\" stroke=\"black\"/>\n", margin, ay, width-margin, ay))
+	sb.WriteString(fmt.Sprintf("<line x1=\"%.2f\" y1=\"%v\" x2=\"%.2f\" y2=\"%v\" stroke=\"black\"/>\n", ax, margin, ax, height-margin))
+	for i, s := range p.Series {
+		color := colors[i%len(colors)]
+		for _, part := range segments(s, yMin, yMax) {
+			points := make([]string, len(part))
+			for j, idx := range part {
+				points[j] = fmt.Sprintf("%.2f,%.2f", sx(s.X[idx]), sy(s.Y[idx]))
+			}
+			sb.WriteString(fmt.Sprintf("<polyline clip-path=\"url(#area)\" fill=\"none\" stroke=\"%v\" stroke-width=\"2\" points=\"%v\"/>\n", color, strings.Join(points, " ")))
+		}
+		// legend
+		ly := margin + 16*i
+		sb.WriteString(fmt.Sprintf("<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"%v\" stroke-width=\"2\"/>\n", width-margin-110, ly, width-margin-90, ly, color))
+		sb.WriteString(fmt.Sprintf("<text x=\"%v\" y=\"%v\" style=\"%v\">%v</text>\n", width-margin-85, ly+4, fontCSS, escape(s.Label)))
+	}
+	for _, pt := range p.Points {
+		if !finite(pt.X) || !finite(pt.Y) {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("<circle cx=\"%.2f\" cy=\"%.2f\" r=\"4\" fill=\"black\"/>\n", sx(pt.X), sy(pt.Y)))
+		label := pt.Label
+		if label == "" {
+			label = fmt.Sprintf("(%v; %v)", formatTick(pt.X), formatTick(pt.Y))
+		}
+		sb.WriteString(fmt.Sprintf("<text x=\"%.2f\" y=\"%.2f\" style=\"%v\">%v</text>\n", sx(pt.X)+6, sy(pt.Y)-6, fontCSS, escape(label)))
+	}
+	sb.WriteString("</svg>\n")
+	return []byte(sb.String())
+}
+
+func (p *Plot) bounds() (xMin, xMax, yMin, yMax float64) {
+	xMin, yMin = math.Inf(1), math.Inf(1)
+	xMax, yMax = math.Inf(-1), math.Inf(-1)
+	ys := make([]float64, 0)
+	for _, s := range p.Series {
+		for i := range s.X {
+			if !finite(s.Y[i]) {
+				continue
+			}
+			xMin, xMax = math.Min(xMin, s.X[i]), math.Max(xMax, s.X[i])
+			ys = append(ys, s.Y[i])
+		}
+	}
+	if len(ys) > 0 {
+		sort.Float64s(ys)
+		yMin, yMax = ys[0], ys[len(ys)-1]
+		// asymptotes like 1/x would make everything else flat, so ignore the most extreme values
+		lo, hi := ys[len(ys)/100], ys[len(ys)-1-len(ys)/100]
+		if hi > lo && yMax-yMin > 10*(hi-lo) {
+			yMin, yMax = lo, hi
+		}
+	}
+	for _, pt := range p.Points {
+		if !finite(pt.X) || !finite(pt.Y) {
+			continue
+		}
+		xMin, xMax = math.Min(xMin, pt.X), math.Max(xMax, pt.X)
+		yMin, yMax = math.Min(yMin, pt.Y), math.Max(yMax, pt.Y)
+	}
+	if !finite(xMin) {
+		xMin, xMax = -1, 1
+	}
+	if !finite(yMin) {
+		yMin, yMax = -1, 1
+	}
+	if xMin == xMax {
+		xMin, xMax = xMin-1, xMax+1
+	}
+	if yMin == yMax {
+		yMin, yMax = yMin-1, yMax+1
+	}
+	pad := (yMax - yMin) * 0.05
+	return xMin, xMax, yMin - pad, yMax + pad
+}
+
+// indices of the connected parts of the series, also split when jumping across the plot, like at an asymptote
+func segments(s Series, yMin, yMax float64) [][]int {
+	res := make([][]int, 0)
+	cur := make([]int, 0)
+	for i := range s.X {
+		jump := i > 0 && ((s.Y[i-1] > yMax && s.Y[i] < yMin) || (s.Y[i-1] < yMin && s.Y[i] > yMax))
+		if finite(s.Y[i]) && !jump {
+			cur = append(cur, i)
+			continue
+		}
+		if finite(s.Y[i]) {
+			if len(cur) > 1 {
+				res = append(res, cur)
+			}
+			cur = []int{i}
+			continue
+		}
+		if len(cur) > 1 {
+			res = append(res, cur)
+		}
+		cur = make([]int, 0)
+	}
+	if len(cur) > 1 {
+		res = append(res, cur)
+	}
+	return res
+}
+
+// around 5-10 ticks at 1, 2 or 5 times a power of 10
+func ticks(min, max float64) []float64 {
+	raw := (max - min) / 8
+	step := math.Pow10(int(math.Floor(math.Log10(raw))))
+	if raw/step >= 5 {
+		step *= 5
+	} else if raw/step >= 2 {
+		step *= 2
+	}
+	res := make([]float64, 0)
+	for t := math.Ceil(min/step) * step; t <= max; t += step {
+		// avoid -0 and tiny rounding errors like 0.30000000000000004
+		res = append(res, math.Round(t/step)*step)
+	}
+	return res
+}
+
+func formatTick(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0
+	}
+	return strings.ReplaceAll(fmt.Sprintf("%v", v), ".", ",")
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
@@ -0,0 +1,142 @@
+package mdcalc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/config"
+	"github.com/eliiasg/mdcalc/diag"
+	"github.com/eliiasg/mdcalc/util"
+)
+
+const defaultProblemGlob = "*.mdc"
+
+// A problem file of a project
+type Problem struct {
+	// file name without .mdc, like 3 or 3a
+	Name string
+	Path string
+}
+
+// finds the problem files of the project in dir, skipped files are reported as warnings to d
+func findProblems(dir string, cfg *config.Config, d *diag.Collector) ([]Problem, error) {
+	patterns := cfg.Problems
+	if len(patterns) == 0 {
+		patterns = []string{defaultProblemGlob}
+	}
+	res := make([]Problem, 0)
+	found := make(map[string]bool)
+	skipped := make(map[string]bool)
+	for _, pattern := range patterns {
+		if !isGlob(pattern) {
+			path := filepath.Join(dir, pattern)
+			if _, err := os.Stat(path); err != nil {
+				return nil, fmt.Errorf("problem file '%v' listed in %v does not exist", pattern, config.FileName)
+			}
+			if found[path] {
+				d.Warnf(config.FileName, 0, 0, "problem file '%v' is listed more than once, only rendering it once", pattern)
+				continue
+			}
+			found[path] = true
+			res = append(res, Problem{Name: problemName(path), Path: path})
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid problem pattern '%v'", pattern)
+		}
+		sortNatural(matches)
+		for _, path := range matches {
+			if found[path] || skipped[path] {
+				continue
+			}
+			name := problemName(path)
+			if !isProblemName(name) {
+				d.Warnf(filepath.Base(path), 0, 0, "skipping file, problem files must be named like 3.mdc or 3a.mdc unless listed in %v", config.FileName)
+				skipped[path] = true
+				continue
+			}
+			found[path] = true
+			res = append(res, Problem{Name: name, Path: path})
+		}
+	}
+	// warn about .mdc files that are not rendered at all, mostly useful when the problems are listed explicitly
+	if len(cfg.Problems) != 0 {
+		all, _ := filepath.Glob(filepath.Join(dir, defaultProblemGlob))
+		sortNatural(all)
+		for _, path := range all {
+			if !found[path] && !skipped[path] {
+				d.Warnf(filepath.Base(path), 0, 0, "skipping file, it is not listed in %v", config.FileName)
+			}
+		}
+	}
+	return res, nil
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+func problemName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// digits optionally followed by letters, like 3 or 3a
+func isProblemName(name string) bool {
+	i := 0
+	for i < len(name) && name[i] >= '0' && name[i] <= '9' {
+		i++
+	}
+	return i > 0 && util.StrIsAlpha(name[i:])
+}
+
+// sorts so 2.mdc < 3.mdc < 3a.mdc < 3b.mdc < 10.mdc
+func sortNatural(paths []string) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		return lessNatural(filepath.Base(paths[i]), filepath.Base(paths[j]))
+	})
+}
+
+func lessNatural(a, b string) bool {
+	for a != "" && b != "" {
+		ca, restA := nextChunk(a)
+		cb, restB := nextChunk(b)
+		if ca != cb {
+			na, numA := chunkNum(ca)
+			nb, numB := chunkNum(cb)
+			if numA && numB && na != nb {
+				return na < nb
+			}
+			if numA != numB {
+				return numA
+			}
+			return ca < cb
+		}
+		a, b = restA, restB
+	}
+	return len(a) < len(b)
+}
+
+// splits of a chunk of only digits or only non digits
+func nextChunk(s string) (string, string) {
+	digit := s[0] >= '0' && s[0] <= '9'
+	i := 1
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digit {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func chunkNum(chunk string) (int, bool) {
+	n := 0
+	for _, c := range chunk {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
@@ -0,0 +1,195 @@
+package mdcalc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/eliiasg/mdcalc/config"
+	"github.com/eliiasg/mdcalc/diag"
+	"github.com/eliiasg/mdcalc/parse"
+	"github.com/eliiasg/mdcalc/syntax"
+	"github.com/eliiasg/mdcalc/unitlib"
+)
+
+// A project directory with problem files, and optionally a config file and unit library files
+type Project struct {
+	Dir      string
+	Config   *config.Config
+	Problems []Problem
+	// warnings from finding the problem files, and errors and warnings from building or checking
+	Diagnostics diag.Collector
+	options     Options
+	lib         syntax.UnitLibrary
+}
+
+// Loads the config, unit library and problem files of the project in dir.
+// Errors are returned as a diag.Diagnostic, with the file they are from
+func OpenProject(dir string, opts ...Option) (*Project, error) {
+	p := &Project{Dir: dir, options: newOptions(opts)}
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, diag.Diagnostic{Severity: diag.Error, File: config.FileName, Message: err.Error()}
+	}
+	p.Config = cfg
+	p.lib = p.options.UnitLibrary
+	if p.lib == nil {
+		p.lib, err = unitlib.NewSavedUnitLib(dir)
+		if err != nil {
+			return nil, diag.Diagnostic{Severity: diag.Error, Message: err.Error()}
+		}
+	}
+	p.Problems, err = findProblems(dir, cfg, &p.Diagnostics)
+	if err != nil {
+		return nil, diag.Diagnostic{Severity: diag.Error, File: config.FileName, Message: err.Error()}
+	}
+	return p, nil
+}
+
+// The paths of every file the build depends on: the config, the unit library, the problem files and the files they load.
+// Files that do not exist yet, like the unit library before anything is saved in it, are included too
+func (p *Project) Files() []string {
+	res := []string{
+		filepath.Join(p.Dir, config.FileName),
+		filepath.Join(p.Dir, unitlib.NamesFileName),
+		filepath.Join(p.Dir, unitlib.OperationsFileName),
+	}
+	for _, problem := range p.Problems {
+		res = append(res, problem.Path)
+		dat, err := os.ReadFile(problem.Path)
+		if err != nil {
+			continue
+		}
+		for _, name := range parse.IncludedFiles(string(dat)) {
+			res = append(res, filepath.Join(p.Dir, name))
+		}
+	}
+	return res
+}
+
+// Only keeps the problem with the name, like 3a, returns false if there is no such problem
+func (p *Project) Select(name string) bool {
+	for _, problem := range p.Problems {
+		if problem.Name == name {
+			p.Problems = []Problem{problem}
+			return true
+		}
+	}
+	return false
+}
+
+// Renders every problem to w, headers are like "{probName} 3a", and assets like plots are written to the project directory.
+// Problems are rendered in parallel, but the output and diagnostics are in the order of the problems.
+// The error is only set if ctx is done or w can not be written to, errors in the problems are added to the diagnostics of p
+func (p *Project) Build(ctx context.Context, w io.Writer, probName, title string) error {
+	results := p.renderAll(ctx, func(problem Problem) string {
+		return fmt.Sprintf("%v %v", probName, problem.Name)
+	}, false)
+	var doc strings.Builder
+	doc.WriteString(fmt.Sprintf("<span style=\"font-size:0\">\n# %v\n</span>\n\n", title))
+	answers := make([]parse.Answer, 0)
+	for i, problem := range p.Problems {
+		out, ok := results[i].out, results[i].ok
+		if !ok {
+			continue
+		}
+		doc.WriteString(out.Text)
+		doc.WriteString("  \n\n")
+		answers = append(answers, out.Answers...)
+		for name, data := range out.Assets {
+			if err := os.WriteFile(filepath.Join(p.Dir, name), data, 0644); err != nil {
+				p.Diagnostics.Errorf(filepath.Base(problem.Path), 0, 0, "%v", err)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.Config.AnswerTable && len(answers) > 0 {
+		doc.WriteString(parse.AnswerTable(answers, p.options.Locale))
+		doc.WriteString("\n")
+	}
+	_, err := io.WriteString(w, doc.String())
+	return err
+}
+
+// Checks every problem without rendering them, and also warns about variables that are never used
+func (p *Project) Check(ctx context.Context) error {
+	p.renderAll(ctx, func(problem Problem) string {
+		return problem.Name
+	}, true)
+	return ctx.Err()
+}
+
+type rendered struct {
+	out parse.Output
+	// false if the file could not be read
+	ok bool
+}
+
+// renders every problem with the worker pool, the diagnostics of every problem are added to p in order
+func (p *Project) renderAll(ctx context.Context, header func(Problem) string, check bool) []rendered {
+	results := make([]rendered, len(p.Problems))
+	diagnostics := make([]diag.Collector, len(p.Problems))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(p.options.Workers, len(p.Problems)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				problem := p.Problems[i]
+				results[i].out, results[i].ok = p.render(ctx, problem, header(problem), check, &diagnostics[i])
+			}
+		}()
+	}
+	for i := range p.Problems {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, d := range diagnostics {
+		p.Diagnostics.Diagnostics = append(p.Diagnostics.Diagnostics, d.Diagnostics...)
+	}
+	return results
+}
+
+func (p *Project) render(ctx context.Context, problem Problem, header string, check bool, d *diag.Collector) (parse.Output, bool) {
+	dat, err := os.ReadFile(problem.Path)
+	if err != nil {
+		d.Errorf(filepath.Base(problem.Path), 0, 0, "%v", err)
+		return parse.Output{}, false
+	}
+	po := parse.Options{
+		Header:       header,
+		Sub:          fmt.Sprintf("%v.<n>", problem.Name),
+		File:         filepath.Base(problem.Path),
+		Dir:          p.Dir,
+		Settings:     p.Config.Settings,
+		EmbedErrors:  p.Config.EmbedErrors || p.options.EmbedErrors,
+		ReportUnused: check,
+	}
+	cache := p.caching() && !check
+	if cache {
+		if entry, ok := p.loadCache(problem, p.cacheKey(problem, dat, po)); ok {
+			d.Diagnostics = append(d.Diagnostics, entry.Diagnostics...)
+			return parse.Output{Text: entry.Text, Answers: entry.Answers, Assets: entry.Assets}, true
+		}
+	}
+	out := renderProblem(ctx, string(dat), po, p.options, p.lib, d)
+	if cache && ctx.Err() == nil {
+		// the key is found again, since rendering can add units to the unit library files
+		p.storeCache(problem, cacheEntry{
+			Key:         p.cacheKey(problem, dat, po),
+			Text:        out.Text,
+			Answers:     out.Answers,
+			Assets:      out.Assets,
+			Diagnostics: d.Diagnostics,
+		})
+	}
+	return out, true
+}
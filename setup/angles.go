@@ -0,0 +1,118 @@
+package setup
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+const (
+	degreeUnit = "deg"
+	radianUnit = "rad"
+	// degrees are written as ^\circ by the formatter
+	degreeName = "°"
+)
+
+// deg and rad work without being in the unit library
+type angleUnits struct {
+	syntax.UnitLibrary
+}
+
+func (l angleUnits) GetUnitDisplayName(unit string) string {
+	switch unit {
+	case degreeUnit:
+		return degreeName
+	case radianUnit:
+		return radianUnit
+	}
+	return l.UnitLibrary.GetUnitDisplayName(unit)
+}
+
+// sin(x) and the other trigonometric functions, which depend on the angle mode
+type trig struct {
+	fn    func(float64) float64
+	latex string
+	// the result is an angle instead of the parameter
+	inverse bool
+}
+
+func (t trig) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	if unit := e.GetUnit(params[0]); unit != "" && (t.inverse || unit != degreeUnit && unit != radianUnit) {
+		return math.NaN(), fmt.Errorf("expected an angle or a number without a unit, got %v", e.UnitLibrary.GetUnitDisplayName(unit))
+	}
+	x, err := e.Evaluate(params[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return syntax.Propagate(func(x []float64) (float64, error) {
+		return t.apply(e, params[0], x[0]), nil
+	}, []syntax.Value{x})
+}
+
+func (t trig) apply(e *syntax.Environment, param syntax.ASTNode, x float64) float64 {
+	if t.inverse {
+		if e.Settings.Radians {
+			return t.fn(x)
+		}
+		return t.fn(x) / math.Pi * 180
+	}
+	if radians(e, param) {
+		return t.fn(x)
+	}
+	return t.fn(x * math.Pi / 180)
+}
+
+// numbers without a unit are marked as degrees, so it is clear which angle mode is used
+func (t trig) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	if t.inverse {
+		arg, err := e.MakeLatexExpression(params[0])
+		if err != nil {
+			return "", err
+		}
+		return t.latex + " " + arg, nil
+	}
+	arg, err := formatAngle(e, params[0])
+	if err != nil {
+		return "", err
+	}
+	return t.latex + " " + arg, nil
+}
+
+func (t trig) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	return nil, nil
+}
+
+func (t trig) Unit(e *syntax.Environment, params []syntax.ASTNode) string {
+	if t.inverse && !e.Settings.Radians {
+		return degreeUnit
+	}
+	return ""
+}
+
+// marks angles without a unit as degrees when not in radian mode
+func formatAngle(e *syntax.Environment, angle syntax.ASTNode) (string, error) {
+	res, err := e.MakeLatexExpression(angle)
+	if err != nil {
+		return "", err
+	}
+	if !radians(e, angle) && e.GetUnit(angle) == "" {
+		// ± is already written in parenthesis
+		if op, ok := angle.(*syntax.ASTOperator); ok && op.Operator != "±" {
+			res = e.Formatter.FormatParenthesie(res)
+		}
+		res += "^{\\circ}"
+	}
+	return res, nil
+}
+
+// whether the angle is in radians, from its unit or otherwise the angle mode
+func radians(e *syntax.Environment, angle syntax.ASTNode) bool {
+	switch e.GetUnit(angle) {
+	case radianUnit:
+		return true
+	case degreeUnit:
+		return false
+	}
+	return e.Settings.Radians
+}
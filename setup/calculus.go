@@ -0,0 +1,596 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/eliiasg/mdcalc/syntax"
+	"github.com/eliiasg/mdcalc/util"
+)
+
+const (
+	integralTolerance = 1e-10
+	integralDepth     = 20
+)
+
+// diff(f, x) and diff(f, x, a), f is either a function of one variable or an expression using x
+type differentiation struct {
+	atPoint bool
+}
+
+func (d differentiation) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	res, name, err := d.derivative(e, params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if !d.atPoint {
+		// x is only set when the derivative is used in a function, like g(x) = diff(f, x)
+		if _, ok := e.VariableValues[name]; !ok {
+			return math.NaN(), fmt.Errorf("diff(f, %v) has no value outside a function, use diff(f, %v, a) for the derivative at %v = a", name, name, name)
+		}
+		return e.Evaluate(res)
+	}
+	a, err := e.Evaluate(params[2])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return e.EvaluateWith(res, name, a)
+}
+
+func (d differentiation) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	expr, name, err := calculusExpression(e, params)
+	if err != nil {
+		return "", err
+	}
+	variable := e.Formatter.FormatVar(name)
+	point := variable
+	if d.atPoint {
+		point, err = e.MakeLatexExpression(params[2])
+		if err != nil {
+			return "", err
+		}
+	}
+	// f'(x) for functions, d/dx (...) for expressions
+	if call, ok := expr.(*syntax.ASTFunction); ok && call != params[0] {
+		return fmt.Sprintf("%v'(%v)", call.Name, point), nil
+	}
+	formatted, err := e.FormatSymbolic(expr, name)
+	if err != nil {
+		return "", err
+	}
+	res := fmt.Sprintf("\\dfrac{d}{d%v}\\left(%v\\right)", variable, formatted)
+	if d.atPoint {
+		res = fmt.Sprintf("\\left.%v\\right|_{%v=%v}", res, variable, point)
+	}
+	return res, nil
+}
+
+// the derivative, and the derivative with the point inserted
+func (d differentiation) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	res, name, err := d.derivative(e, params)
+	if err != nil {
+		return nil, err
+	}
+	if !d.atPoint {
+		formatted, err := e.FormatSymbolic(res, name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{formatted}, nil
+	}
+	if uses(res, name) == 0 {
+		return nil, nil
+	}
+	a, err := e.Evaluate(params[2])
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]string, 0, 2)
+	symbolic, err := e.FormatSymbolic(res, name)
+	if err != nil {
+		return nil, err
+	}
+	err = e.With(name, a, func() error {
+		formatted, err := e.MakeLatexExpression(res)
+		steps = append(steps, formatted)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the derivative is only shown on its own for expressions, for functions it is shown when it is defined
+	if _, ok := params[0].(*syntax.ASTLiteral); !ok {
+		steps = append([]string{symbolic}, steps...)
+	}
+	return steps, nil
+}
+
+// g(x) = diff(f, x) defines g as the derivative of f
+func (d differentiation) Expand(e *syntax.Environment, params []syntax.ASTNode) (syntax.ASTNode, error) {
+	if d.atPoint {
+		return nil, nil
+	}
+	res, _, err := d.derivative(e, params)
+	return res, err
+}
+
+func (d differentiation) derivative(e *syntax.Environment, params []syntax.ASTNode) (syntax.ASTNode, string, error) {
+	expr, name, err := calculusExpression(e, params)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := derive(e, expr, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return simplify(res), name, nil
+}
+
+// integrate(f, x, a, b)
+type integral struct{}
+
+func (i integral) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	expr, name, err := calculusExpression(e, params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	a, err := numberParam(e, params[2])
+	if err != nil {
+		return math.NaN(), err
+	}
+	b, err := numberParam(e, params[3])
+	if err != nil {
+		return math.NaN(), err
+	}
+	var firstErr error
+	f := func(x float64) float64 {
+		val, err := e.EvaluateWith(expr, name, x)
+		if err == nil {
+			var n float64
+			n, err = syntax.Number(val)
+			if err == nil {
+				return n
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		return math.NaN()
+	}
+	res := adaptiveSimpson(f, a, b)
+	if firstErr != nil {
+		return math.NaN(), firstErr
+	}
+	if math.IsNaN(res) || math.IsInf(res, 0) {
+		return math.NaN(), errors.New("the integral does not have a value")
+	}
+	return res, nil
+}
+
+func (i integral) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	expr, name, err := calculusExpression(e, params)
+	if err != nil {
+		return "", err
+	}
+	return formatIntegral(e, expr, name, params)
+}
+
+// the body of the function, if a function is integrated
+func (i integral) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	expr, name, err := calculusExpression(e, params)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expr.(*syntax.ASTFunction)
+	if !ok || call == params[0] {
+		return nil, nil
+	}
+	user := e.Functions[call.Name][1]
+	res, err := formatIntegral(e, substitute(user.Body, map[string]syntax.ASTNode{user.Params[0]: &syntax.ASTLiteral{Value: name}}), name, params)
+	if err != nil {
+		return nil, err
+	}
+	return []string{res}, nil
+}
+
+func formatIntegral(e *syntax.Environment, expr syntax.ASTNode, name string, params []syntax.ASTNode) (string, error) {
+	a, err := e.MakeLatexExpression(params[2])
+	if err != nil {
+		return "", err
+	}
+	b, err := e.MakeLatexExpression(params[3])
+	if err != nil {
+		return "", err
+	}
+	formatted, err := e.FormatSymbolic(expr, name)
+	if err != nil {
+		return "", err
+	}
+	if op, ok := expr.(*syntax.ASTOperator); ok && (op.Operator == "+" || op.Operator == "-") {
+		formatted = e.Formatter.FormatParenthesie(formatted)
+	}
+	return fmt.Sprintf("\\int_{%v}^{%v} %v\\,d%v", a, b, formatted, e.Formatter.FormatVar(name)), nil
+}
+
+func adaptiveSimpson(f func(float64) float64, a, b float64) float64 {
+	m := (a + b) / 2
+	fa, fm, fb := f(a), f(m), f(b)
+	whole := (b - a) / 6 * (fa + 4*fm + fb)
+	return simpsonStep(f, a, b, fa, fm, fb, whole, integralTolerance, integralDepth)
+}
+
+// splits the interval in two until simpsons rule gives the same result for both halves as for the whole
+func simpsonStep(f func(float64) float64, a, b, fa, fm, fb, whole, tolerance float64, depth int) float64 {
+	m := (a + b) / 2
+	lm, rm := (a+m)/2, (m+b)/2
+	flm, frm := f(lm), f(rm)
+	left := (m - a) / 6 * (fa + 4*flm + fm)
+	right := (b - m) / 6 * (fm + 4*frm + fb)
+	diff := left + right - whole
+	if depth <= 0 || math.Abs(diff) <= 15*tolerance || math.IsNaN(diff) {
+		return left + right + diff/15
+	}
+	return simpsonStep(f, a, m, fa, flm, fm, left, tolerance/2, depth-1) +
+		simpsonStep(f, m, b, fm, frm, fb, right, tolerance/2, depth-1)
+}
+
+// the expression and the name of the variable, a function name f becomes f(x)
+func calculusExpression(e *syntax.Environment, params []syntax.ASTNode) (syntax.ASTNode, string, error) {
+	name, ok := params[1].(*syntax.ASTLiteral)
+	if !ok || util.StrIsNum(name.Value) {
+		return nil, "", errors.New("second parameter must be the name of a variable")
+	}
+	if lit, ok := params[0].(*syntax.ASTLiteral); ok {
+		if user, ok := e.Functions[lit.Value][1]; ok && user.Body != nil {
+			return &syntax.ASTFunction{Name: lit.Value, Params: []syntax.ASTNode{name}}, name.Value, nil
+		}
+	}
+	return params[0], name.Value, nil
+}
+
+// the derivative of node with respect to x, it should be simplified afterwards
+func derive(e *syntax.Environment, node syntax.ASTNode, x string) (syntax.ASTNode, error) {
+	// user defined functions can use other user defined functions, so they are written out first
+	if call, ok := node.(*syntax.ASTFunction); ok {
+		if user, ok := e.Functions[call.Name][len(call.Params)]; ok && user.Body != nil {
+			vars := make(map[string]syntax.ASTNode)
+			for i, p := range user.Params {
+				vars[p] = call.Params[i]
+			}
+			return derive(e, substitute(user.Body, vars), x)
+		}
+	}
+	if uses(node, x) == 0 {
+		return number(0), nil
+	}
+	switch n := node.(type) {
+	case *syntax.ASTLiteral:
+		return number(1), nil
+	case *syntax.ASTUnitOverride:
+		return derive(e, n.Child, x)
+	case *syntax.ASTComment:
+		return derive(e, n.Child, x)
+	case *syntax.ASTPercent:
+		d, err := derive(e, n.Child, x)
+		return op("/", d, number(100)), err
+	case *syntax.ASTOperator:
+		return deriveOperator(e, percentProduct(n), x)
+	case *syntax.ASTFunction:
+		return deriveFunction(e, n, x)
+	}
+	return nil, errors.New("cannot differentiate this expression")
+}
+
+func deriveOperator(e *syntax.Environment, n *syntax.ASTOperator, x string) (syntax.ASTNode, error) {
+	l, r := n.Left, n.Right
+	dl, err := derive(e, l, x)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := derive(e, r, x)
+	if err != nil {
+		return nil, err
+	}
+	switch n.Operator {
+	case "+", "-":
+		return op(n.Operator, dl, dr), nil
+	case "*":
+		return op("+", op("*", dl, r), op("*", l, dr)), nil
+	case "/", "%":
+		if uses(r, x) == 0 {
+			return op("/", dl, r), nil
+		}
+		return op("/", op("-", op("*", dl, r), op("*", l, dr)), op("^", r, number(2))), nil
+	case "^":
+		if uses(r, x) == 0 {
+			return op("*", op("*", r, op("^", l, op("-", r, number(1)))), dl), nil
+		}
+		if uses(l, x) == 0 {
+			return op("*", op("*", n, fun("ln", l)), dr), nil
+		}
+		return op("*", n, op("+", op("*", dr, fun("ln", l)), op("/", op("*", r, dl), l))), nil
+	}
+	return nil, fmt.Errorf("cannot differentiate operator '%v'", n.Operator)
+}
+
+func deriveFunction(e *syntax.Environment, n *syntax.ASTFunction, x string) (syntax.ASTNode, error) {
+	if len(n.Params) == 0 {
+		return number(0), nil
+	}
+	u := n.Params[len(n.Params)-1]
+	du, err := derive(e, u, x)
+	if err != nil {
+		return nil, err
+	}
+	// the base of logarithms and roots must be constant
+	if len(n.Params) == 2 && uses(n.Params[0], x) != 0 {
+		return nil, fmt.Errorf("cannot differentiate '%v' with a variable base", n.Name)
+	}
+	switch n.Name {
+	case "par":
+		return du, nil
+	case "neg":
+		return fun("neg", du), nil
+	case "sqrt":
+		return op("/", du, op("*", number(2), n)), nil
+	case "root":
+		return derive(e, op("^", u, op("/", number(1), n.Params[0])), x)
+	case "ln":
+		return op("/", du, u), nil
+	case "log10":
+		return op("/", du, op("*", u, fun("ln", number(10)))), nil
+	case "log":
+		return op("/", du, op("*", u, fun("ln", n.Params[0]))), nil
+	case "abs":
+		return op("*", op("/", u, n), du), nil
+	case "sin":
+		return op("*", op("*", fun("cos", u), angleFactor(e, u)), du), nil
+	case "cos":
+		return fun("neg", op("*", op("*", fun("sin", u), angleFactor(e, u)), du)), nil
+	case "tan":
+		return op("/", op("*", angleFactor(e, u), du), op("^", fun("cos", u), number(2))), nil
+	case "asin", "acos":
+		res := op("/", du, op("*", angleFactor(e, u), fun("sqrt", op("-", number(1), op("^", u, number(2))))))
+		if n.Name == "acos" {
+			return fun("neg", res), nil
+		}
+		return res, nil
+	case "atan":
+		return op("/", du, op("*", angleFactor(e, u), op("+", number(1), op("^", u, number(2))))), nil
+	}
+	return nil, fmt.Errorf("cannot differentiate function '%v'", n.Name)
+}
+
+// the derivatives have an extra factor when the angle is in degrees
+func angleFactor(e *syntax.Environment, angle syntax.ASTNode) syntax.ASTNode {
+	if radians(e, angle) {
+		return number(1)
+	}
+	return op("/", fun("pi"), number(180))
+}
+
+// Removes things like multiplying by 1 and adding 0, and calculates operators on two numbers
+func simplify(node syntax.ASTNode) syntax.ASTNode {
+	switch n := node.(type) {
+	case *syntax.ASTOperator:
+		return simplifyOperator(n.Operator, simplify(n.Left), simplify(n.Right))
+	case *syntax.ASTFunction:
+		params := make([]syntax.ASTNode, len(n.Params))
+		for i, p := range n.Params {
+			params[i] = simplify(p)
+		}
+		if n.Name == "neg" && len(params) == 1 {
+			if c, ok := constant(params[0]); ok {
+				return number(-c)
+			}
+			if inner, ok := negated(params[0]); ok {
+				return inner
+			}
+		}
+		if n.Name == "ln" && len(params) == 1 {
+			if inner, ok := params[0].(*syntax.ASTFunction); ok && inner.Name == "e" {
+				return number(1)
+			}
+		}
+		return fun(n.Name, params...)
+	case *syntax.ASTUnitOverride:
+		return simplify(n.Child)
+	case *syntax.ASTComment:
+		return simplify(n.Child)
+	}
+	return node
+}
+
+func simplifyOperator(operator string, l, r syntax.ASTNode) syntax.ASTNode {
+	lc, lConst := constant(l)
+	rc, rConst := constant(r)
+	if lConst && rConst {
+		if res, ok := fold(operator, lc, rc); ok {
+			return number(res)
+		}
+	}
+	switch operator {
+	case "+":
+		if lConst && lc == 0 {
+			return r
+		}
+		if rConst && rc == 0 {
+			return l
+		}
+		if inner, ok := negated(r); ok {
+			return simplifyOperator("-", l, inner)
+		}
+	case "-":
+		if rConst && rc == 0 {
+			return l
+		}
+		if lConst && lc == 0 {
+			return simplify(fun("neg", r))
+		}
+		if inner, ok := negated(r); ok {
+			return simplifyOperator("+", l, inner)
+		}
+	case "*":
+		if (lConst && lc == 0) || (rConst && rc == 0) {
+			return number(0)
+		}
+		if lConst && lc == 1 {
+			return r
+		}
+		if rConst && rc == 1 {
+			return l
+		}
+		// numbers are written first, like 2x, two numbers are left as they are when they can not be folded
+		if rConst && !lConst {
+			return simplifyOperator("*", r, l)
+		}
+		if inner, ok := negated(l); ok {
+			return fun("neg", simplifyOperator("*", inner, r))
+		}
+		if inner, ok := negated(r); ok {
+			return fun("neg", simplifyOperator("*", l, inner))
+		}
+		// a*(b*c) is written as a*b*c and a*(b/c) as a*b/c to avoid parenthesis
+		// but not a*(b*2) where b*2 could not be folded, since the 2 would be moved back first
+		if rOp, ok := r.(*syntax.ASTOperator); ok && (rOp.Operator == "*" || rOp.Operator == "/") && !unfolded(rOp) {
+			return simplifyOperator(rOp.Operator, simplifyOperator("*", l, rOp.Left), rOp.Right)
+		}
+	case "/", "%":
+		if rConst && rc == 1 {
+			return l
+		}
+		if lConst && lc == 0 {
+			return number(0)
+		}
+	case "^":
+		if rConst && rc == 1 {
+			return l
+		}
+		if rConst && rc == 0 {
+			return number(1)
+		}
+	}
+	return op(operator, l, r)
+}
+
+// a product ending with a number, which is only left by simplify when the numbers could not be folded
+func unfolded(node *syntax.ASTOperator) bool {
+	_, ok := constant(node.Right)
+	return node.Operator == "*" && ok
+}
+
+// only gives whole numbers for divisions, so 1/3 stays a fraction
+func fold(operator string, l, r float64) (float64, bool) {
+	var res float64
+	switch operator {
+	case "+":
+		res = l + r
+	case "-":
+		res = l - r
+	case "*":
+		res = l * r
+	case "^":
+		res = math.Pow(l, r)
+	case "/", "%":
+		if r == 0 || l/r != math.Trunc(l/r) {
+			return 0, false
+		}
+		res = l / r
+	default:
+		return 0, false
+	}
+	return res, !math.IsNaN(res) && !math.IsInf(res, 0)
+}
+
+func constant(node syntax.ASTNode) (float64, bool) {
+	switch n := node.(type) {
+	case *syntax.ASTLiteral:
+		if !util.StrIsNum(n.Value) {
+			return 0, false
+		}
+		res, err := strconv.ParseFloat(n.Value, 64)
+		return res, err == nil
+	case *syntax.ASTFunction:
+		if n.Name == "neg" && len(n.Params) == 1 {
+			res, ok := constant(n.Params[0])
+			return -res, ok
+		}
+	}
+	return 0, false
+}
+
+func negated(node syntax.ASTNode) (syntax.ASTNode, bool) {
+	if n, ok := node.(*syntax.ASTFunction); ok && n.Name == "neg" && len(n.Params) == 1 {
+		return n.Params[0], true
+	}
+	return nil, false
+}
+
+// a copy of node with the variables replaced
+func substitute(node syntax.ASTNode, vars map[string]syntax.ASTNode) syntax.ASTNode {
+	switch n := node.(type) {
+	case *syntax.ASTLiteral:
+		if v, ok := vars[n.Value]; ok {
+			return v
+		}
+	case *syntax.ASTUnitOverride:
+		return &syntax.ASTUnitOverride{Unit: n.Unit, Child: substitute(n.Child, vars)}
+	case *syntax.ASTComment:
+		return &syntax.ASTComment{Content: n.Content, Child: substitute(n.Child, vars)}
+	case *syntax.ASTPercent:
+		return &syntax.ASTPercent{Child: substitute(n.Child, vars)}
+	case *syntax.ASTOperator:
+		return op(n.Operator, substitute(n.Left, vars), substitute(n.Right, vars))
+	case *syntax.ASTFunction:
+		params := make([]syntax.ASTNode, len(n.Params))
+		for i, p := range n.Params {
+			params[i] = substitute(p, vars)
+		}
+		return fun(n.Name, params...)
+	case *syntax.ASTList:
+		items := make([]syntax.ASTNode, len(n.Items))
+		for i, item := range n.Items {
+			items[i] = substitute(item, vars)
+		}
+		return &syntax.ASTList{Items: items}
+	case *syntax.ASTMatrix:
+		rows := make([][]syntax.ASTNode, len(n.Rows))
+		for i, row := range n.Rows {
+			rows[i] = make([]syntax.ASTNode, len(row))
+			for j, item := range row {
+				rows[i][j] = substitute(item, vars)
+			}
+		}
+		return &syntax.ASTMatrix{Rows: rows}
+	}
+	return node
+}
+
+// a+8% as a*(1+8%), since it is 8% of a that is added
+func percentProduct(node *syntax.ASTOperator) *syntax.ASTOperator {
+	if !syntax.PercentChange(node) {
+		return node
+	}
+	return &syntax.ASTOperator{Operator: "*", Left: node.Left, Right: op(node.Operator, number(1), node.Right)}
+}
+
+func number(n float64) syntax.ASTNode {
+	// there are no negative number literals
+	if n < 0 {
+		return fun("neg", number(-n))
+	}
+	if n == 0 {
+		return &syntax.ASTLiteral{Value: "0"}
+	}
+	return &syntax.ASTLiteral{Value: strconv.FormatFloat(n, 'f', -1, 64)}
+}
+
+func op(operator string, l, r syntax.ASTNode) syntax.ASTNode {
+	return &syntax.ASTOperator{Operator: operator, Left: l, Right: r}
+}
+
+func fun(name string, params ...syntax.ASTNode) syntax.ASTNode {
+	return &syntax.ASTFunction{Name: name, Params: params}
+}
@@ -0,0 +1,109 @@
+package setup
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+func TestCalculus(t *testing.T) {
+	tests := []struct {
+		code     string
+		settings []string
+		want     float64
+		err      string
+	}{
+		{code: "diff(x^3, x, 2)", want: 12},
+		{code: "diff(5, x, 2)", want: 0},
+		{code: "diff(3*x - 2, x, 7)", want: 3},
+		{code: "diff(x^2*ln(x), x, 1)", want: 1},
+		{code: "diff(sqrt(x), x, 4)", want: 0.25},
+		{code: "diff(e()^(2*x), x, 0)", want: 2},
+		{code: "diff(sin(x), x, 0)", settings: []string{"angles rad"}, want: 1},
+		{code: "diff(sin(x), x, 0)", settings: []string{"angles deg"}, want: math.Pi / 180},
+		{code: "diff(-x^2, x, 3)", want: -6},
+		{code: "diff(x^2, x)", err: "outside a function"},
+		{code: "diff(x^2, 2, 3)", err: "second parameter must be the name"},
+		{code: "diff(x*y, x, 1)", err: "variable 'y' undefined"},
+		{code: "integrate(x^2, x, 0, 3)", want: 9},
+		{code: "integrate(2, x, 1, 4)", want: 6},
+		{code: "integrate(x, x, 3, 1)", want: -4},
+		{code: "integrate(-x, x, 0, 2)", want: -2},
+		{code: "integrate(1/x, x, 1, 2)", want: math.Ln2},
+		{code: "integrate(sin(x), x, 0, pi())", settings: []string{"angles rad"}, want: 2},
+		{code: "integrate(1/x, x, 0, 1)", err: "divide by zero"},
+		{code: "integrate(ln(x), x, -1, 1)", err: "does not have a value"},
+		{code: "integrate(x^2, 0, 0, 1)", err: "second parameter must be the name"},
+		{code: "integrate(x*y, x, 0, 1)", err: "variable 'y' undefined"},
+	}
+	for _, test := range tests {
+		res, err := calculate(t, test.code, test.settings...)
+		if checkError(t, test.code, err, test.err) || test.err != "" {
+			continue
+		}
+		if n, _ := syntax.Number(res); !near(n, test.want, 1e-6) {
+			t.Errorf("%v: expected %v, got %v", test.code, test.want, res)
+		}
+	}
+}
+
+func TestAdaptiveSimpson(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(float64) float64
+		a, b float64
+		want float64
+	}{
+		{name: "cubic", f: func(x float64) float64 { return x * x * x }, a: 0, b: 2, want: 4},
+		{name: "exponential", f: math.Exp, a: 0, b: 1, want: math.E - 1},
+		{name: "empty", f: math.Exp, a: 1, b: 1, want: 0},
+		{name: "reversed", f: math.Sin, a: math.Pi, b: 0, want: -2},
+		{name: "narrow peak", f: func(x float64) float64 { return 1 / (1 + 1e4*x*x) }, a: -1, b: 1, want: 2 * math.Atan(100) / 100},
+	}
+	for _, test := range tests {
+		if res := adaptiveSimpson(test.f, test.a, test.b); !near(res, test.want, 1e-8) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.want, res)
+		}
+	}
+	if res := adaptiveSimpson(math.Log, -1, 1); !math.IsNaN(res) {
+		t.Errorf("expected NaN when f is not defined, got %v", res)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	x := &syntax.ASTLiteral{Value: "x"}
+	tests := []struct {
+		name string
+		node syntax.ASTNode
+		// the constant the result should be, if it is a constant
+		want    float64
+		isConst bool
+	}{
+		{name: "fold", node: op("*", number(2), op("+", number(1), number(2))), want: 6, isConst: true},
+		{name: "times 0", node: op("*", x, number(0)), want: 0, isConst: true},
+		{name: "power of 0", node: op("^", x, number(0)), want: 1, isConst: true},
+		{name: "double negation", node: fun("neg", fun("neg", number(3))), want: 3, isConst: true},
+		{name: "ln e", node: fun("ln", fun("e")), want: 1, isConst: true},
+		{name: "fraction", node: op("/", number(1), number(3))},
+		{name: "overflow", node: op("*", number(1e200), number(1e200))},
+		{name: "overflow times x", node: op("*", x, op("*", number(1e200), number(1e200)))},
+	}
+	for _, test := range tests {
+		res := simplify(test.node)
+		c, ok := constant(res)
+		if ok != test.isConst || (ok && c != test.want) {
+			t.Errorf("%v: expected %v (constant: %v), got %#v", test.name, test.want, test.isConst, res)
+		}
+	}
+	// x*1 and 0+x are just x
+	for _, node := range []syntax.ASTNode{op("*", x, number(1)), op("+", number(0), x), op("^", x, number(1))} {
+		if res := simplify(node); res != x {
+			t.Errorf("expected x, got %#v", res)
+		}
+	}
+	// numbers are moved in front
+	if res, ok := simplify(op("*", x, number(2))).(*syntax.ASTOperator); !ok || res.Right != x {
+		t.Errorf("expected 2*x, got %#v", res)
+	}
+}
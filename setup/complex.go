@@ -0,0 +1,99 @@
+package setup
+
+import (
+	"math"
+	"math/cmplx"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// integer powers are multiplied out, so i^2 is exactly -1
+func complexPow(l, r complex128) (complex128, error) {
+	n := real(r)
+	if imag(r) != 0 || n != math.Trunc(n) || math.Abs(n) > 64 {
+		return cmplx.Pow(l, r), nil
+	}
+	res := complex(1, 0)
+	for i := 0; i < int(math.Abs(n)); i++ {
+		res *= l
+	}
+	if n < 0 {
+		return complexDiv(1, res)
+	}
+	return res, nil
+}
+
+// arg(z), the angle of a complex number in the angle mode
+type argument struct{}
+
+func (a argument) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	val, err := e.Evaluate(params[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	z, err := syntax.Complex(val)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if e.Settings.Radians {
+		return cmplx.Phase(z), nil
+	}
+	return cmplx.Phase(z) / math.Pi * 180, nil
+}
+
+func (a argument) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	res, err := e.MakeLatexExpression(params[0])
+	if err != nil {
+		return "", err
+	}
+	return "\\arg(" + res + ")", nil
+}
+
+func (a argument) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	return nil, nil
+}
+
+func (a argument) Unit(e *syntax.Environment, params []syntax.ASTNode) string {
+	if e.Settings.Radians {
+		return ""
+	}
+	return degreeUnit
+}
+
+// polar(r, angle), a complex number from its length and angle
+type polar struct{}
+
+func (p polar) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	r, err := numberParam(e, params[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	angle, err := numberParam(e, params[1])
+	if err != nil {
+		return math.NaN(), err
+	}
+	if !radians(e, params[1]) {
+		angle = angle * math.Pi / 180
+	}
+	return syntax.ComplexValue(cmplx.Rect(r, angle)), nil
+}
+
+func (p polar) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	r, err := e.MakeLatexExpression(params[0])
+	if err != nil {
+		return "", err
+	}
+	angle, err := formatAngle(e, params[1])
+	if err != nil {
+		return "", err
+	}
+	return r + "\\angle " + angle, nil
+}
+
+func (p polar) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	return nil, nil
+}
+
+func (p polar) Unit(e *syntax.Environment, params []syntax.ASTNode) string {
+	return e.GetUnit(params[0])
+}
@@ -0,0 +1,115 @@
+package setup
+
+import (
+	"math"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// comparisons written the other way around, for showing why a branch was not taken
+var negatedComparisons = map[string]string{
+	"<":  ">=",
+	"<=": ">",
+	">":  "<=",
+	">=": "<",
+	"==": "!=",
+	"!=": "==",
+}
+
+// if(cond, a, b) or if(cond1, a, cond2, b, ..., otherwise), only the taken branch is evaluated
+type conditional struct {
+	locale Locale
+}
+
+// the index of the taken branch
+func (c conditional) branch(e *syntax.Environment, params []syntax.ASTNode) (int, error) {
+	for i := 0; i+1 < len(params); i += 2 {
+		cond, err := numberParam(e, params[i])
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return i + 1, nil
+		}
+	}
+	return len(params) - 1, nil
+}
+
+func (c conditional) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	i, err := c.branch(e, params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return e.Evaluate(params[i])
+}
+
+// every branch as cases when written by name, otherwise only the taken branch with the condition as a comment
+func (c conditional) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	if e.Symbolic() {
+		return c.cases(e, params)
+	}
+	i, err := c.branch(e, params)
+	if err != nil {
+		return "", err
+	}
+	res, err := e.MakeLatexExpression(params[i])
+	if err != nil {
+		return "", err
+	}
+	reason, err := c.reason(e, params, i)
+	if err != nil {
+		return "", err
+	}
+	return res + "\\textit{ (}" + reason + "\\textit{)}", nil
+}
+
+// the condition of the taken branch, or the last condition negated for the otherwise branch
+func (c conditional) reason(e *syntax.Environment, params []syntax.ASTNode, i int) (string, error) {
+	if i != len(params)-1 {
+		return e.MakeLatexExpression(params[i-1])
+	}
+	cond, ok := params[i-2].(*syntax.ASTOperator)
+	negated, comparison := "", false
+	if ok {
+		negated, comparison = negatedComparisons[cond.Operator]
+	}
+	if !comparison || len(params) > 3 {
+		return "\\textit{" + c.locale.Otherwise + "}", nil
+	}
+	return e.MakeLatexExpression(op(negated, cond.Left, cond.Right))
+}
+
+func (c conditional) cases(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	rows := make([]string, 0, len(params)/2+1)
+	for i := 0; i+1 < len(params); i += 2 {
+		cond, err := e.MakeLatexExpression(params[i])
+		if err != nil {
+			return "", err
+		}
+		val, err := e.MakeLatexExpression(params[i+1])
+		if err != nil {
+			return "", err
+		}
+		rows = append(rows, val+" & \\text{"+c.locale.If+" } "+cond)
+	}
+	otherwise, err := e.MakeLatexExpression(params[len(params)-1])
+	if err != nil {
+		return "", err
+	}
+	rows = append(rows, otherwise+" & \\text{"+c.locale.Otherwise+"}")
+	return "\\begin{cases}" + strings.Join(rows, "\\\\") + "\\end{cases}", nil
+}
+
+func (c conditional) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	return nil, nil
+}
+
+// the unit of the taken branch, or the first branch if the conditions can not be evaluated
+func (c conditional) Unit(e *syntax.Environment, params []syntax.ASTNode) string {
+	i, err := c.branch(e, params)
+	if err != nil {
+		return e.GetUnit(params[1])
+	}
+	return e.GetUnit(params[i])
+}
@@ -0,0 +1,69 @@
+package setup
+
+import (
+	"errors"
+	"math"
+)
+
+// K0, r, n
+func futureValue(args []float64) (float64, error) {
+	if args[1] <= -1 {
+		return math.NaN(), errors.New("interest rate must be above -1")
+	}
+	return args[0] * math.Pow(1+args[1], args[2]), nil
+}
+
+// Kn, r, n
+func presentValue(args []float64) (float64, error) {
+	if args[1] <= -1 {
+		return math.NaN(), errors.New("interest rate must be above -1")
+	}
+	return args[0] * math.Pow(1+args[1], -args[2]), nil
+}
+
+// the interest earned on K0, r, n
+func interest(args []float64) (float64, error) {
+	kn, err := futureValue(args)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return kn - args[0], nil
+}
+
+// the payment per period for a loan of G, r, n
+func annuityPayment(args []float64) (float64, error) {
+	if args[2] <= 0 {
+		return math.NaN(), errors.New("amount of periods must be positive")
+	}
+	if args[1] <= -1 {
+		return math.NaN(), errors.New("interest rate must be above -1")
+	}
+	if args[1] == 0 {
+		return args[0] / args[2], nil
+	}
+	return args[0] * args[1] / (1 - math.Pow(1+args[1], -args[2])), nil
+}
+
+// the amount of periods for K0 to grow to Kn, K0, r, Kn
+func periods(args []float64) (float64, error) {
+	if args[1] <= -1 || args[1] == 0 {
+		return math.NaN(), errors.New("interest rate must be above -1 and not 0")
+	}
+	if args[2]/args[0] <= 0 {
+		return math.NaN(), errors.New("start and end capital must have the same sign")
+	}
+	return math.Log(args[2]/args[0]) / math.Log(1+args[1]), nil
+}
+
+// the yearly rate from the nominal rate r with m periods per year
+func effectiveRate(args []float64) (float64, error) {
+	if args[1] <= 0 {
+		return math.NaN(), errors.New("periods per year must be positive")
+	}
+	return math.Pow(1+args[0]/args[1], args[1]) - 1, nil
+}
+
+// the relative change from a to b
+func change(args []float64) (float64, error) {
+	return div(args[1]-args[0], args[0])
+}
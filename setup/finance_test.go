@@ -0,0 +1,41 @@
+package setup
+
+import (
+	"math"
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+func TestFinance(t *testing.T) {
+	tests := []struct {
+		code string
+		want float64
+		err  string
+	}{
+		{code: "fv(1000, 0.05, 2)", want: 1102.5},
+		{code: "pv(1102.5, 0.05, 2)", want: 1000},
+		{code: "interest(1000, 0.05, 2)", want: 102.5},
+		{code: "ydelse(1000, 0, 4)", want: 250},
+		{code: "ydelse(1000, 0.05, 2)", want: 537.8048780487805},
+		{code: "periods(1000, 0.05, 2000)", want: math.Log(2) / math.Log(1.05)},
+		{code: "periods(2000, 0.05, 1000)", want: -math.Log(2) / math.Log(1.05)},
+		{code: "effective(0.12, 12)", want: math.Pow(1.01, 12) - 1},
+		{code: "change(80, 100)", want: 0.25},
+		{code: "fv(1000, -1, 2)", err: "interest rate must be above -1"},
+		{code: "ydelse(1000, 0.05, 0)", err: "amount of periods must be positive"},
+		{code: "periods(1000, 0, 2000)", err: "interest rate must be above -1 and not 0"},
+		{code: "periods(1000, 0.05, -2000)", err: "must have the same sign"},
+		{code: "effective(0.12, 0)", err: "periods per year must be positive"},
+		{code: "change(0, 100)", err: "divide by zero"},
+	}
+	for _, test := range tests {
+		res, err := calculate(t, test.code)
+		if checkError(t, test.code, err, test.err) || test.err != "" {
+			continue
+		}
+		if n, _ := syntax.Number(res); !near(n, test.want, 1e-9) {
+			t.Errorf("%v: expected %v, got %v", test.code, test.want, res)
+		}
+	}
+}
@@ -3,36 +3,178 @@ package setup
 import (
 	"fmt"
 	"math"
+	"math/cmplx"
 	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
 )
 
-type formatter struct{}
+// columns in a rendered data table before wrapping to a new row
+const tableWidth = 10
+
+type formatter struct {
+	// for how complex numbers are written
+	settings *syntax.Settings
+	locale   Locale
+}
+
+// The formatter used by the environment, settings should be the settings of the environment
+func NewFormatter(settings *syntax.Settings, locale Locale) syntax.Formatter {
+	return &formatter{settings: settings, locale: locale}
+}
 
 func (f *formatter) FormatLine(expr string, res string) string {
 	return expr + " &= " + res + "\\\\ \\\\ \n"
 }
 
 // precision -1 for number in expression
-func (f *formatter) FormatNumber(num float64, precision int, unit string, comment string) string {
-	if precision == -1 {
-		precision = 10
+func (f *formatter) FormatNumber(num syntax.Value, precision int, unit string, comment string) string {
+	degrees := unit == degreeName
+	unit = formatUnit(unit)
+	if comment != "" {
+		comment = fmt.Sprintf("\\textit{ (%v)}", comment)
+	}
+	switch n := num.(type) {
+	case syntax.Matrix:
+		cells := make([][]string, len(n))
+		for i, row := range n {
+			cells[i] = make([]string, len(row))
+			for j, item := range row {
+				cells[i][j] = f.formatFloat(item, precision)
+			}
+		}
+		return fmt.Sprintf("%v%v%v", pmatrix(cells), unit, comment)
+	case syntax.Uncertain:
+		// enough decimals that the uncertainty is not rounded to 0, the value is written with the same decimals
+		for precision != -1 && precision < 10 && f.formatFloat(n.Uncertainty, precision) == "0" && n.Uncertainty != 0 {
+			precision++
+		}
+		return fmt.Sprintf("(\\textbf{%v}\\pm\\textbf{%v})%v%v", f.formatFloat(n.Value, precision), f.formatFloat(n.Uncertainty, precision), unit, comment)
+	case syntax.Interval:
+		return fmt.Sprintf("[%v;\\,%v]%v%v", f.formatBound(n.Lo, precision, math.Floor), f.formatBound(n.Hi, precision, math.Ceil), unit, comment)
+	case syntax.List:
+		if precision == -1 {
+			return fmt.Sprintf("\\{%v\\}%v", f.formatItems(n, precision, ";\\,"), unit)
+		}
+		return fmt.Sprintf("%v%v%v", f.formatTable(n, precision), unit, comment)
+	}
+	if c, ok := num.(complex128); ok {
+		return fmt.Sprintf("%v%v%v", f.formatComplex(c, precision), unit, comment)
+	}
+	n, _ := syntax.Number(num)
+	if degrees {
+		return fmt.Sprintf("\\textbf{%v}^{\\circ}%v", f.formatFloat(n, precision), comment)
+	}
+	return fmt.Sprintf("\\textbf{%v}%v%v", f.formatFloat(n, precision), unit, comment)
+}
+
+// display names starting with \ are latex, like the fractions of units written in brackets
+func formatUnit(unit string) string {
+	if strings.HasPrefix(unit, "\\") {
+		return "\\,{\\scriptstyle " + unit + "}"
 	}
-	amt := math.Pow10(precision)
-	num = math.Round(num*amt) / amt
 	if unit != "" {
 		unit = " " + unit
 	}
-	if comment != "" {
-		comment = fmt.Sprintf("\\textit{ (%v)}", comment)
+	return "\\text{\\scriptsize{" + unit + "}}"
+}
+
+// a+bi, or r∠θ in polar form, parts that round to 0 are left out
+func (f *formatter) formatComplex(c complex128, precision int) string {
+	if f.settings.Polar {
+		angle := cmplx.Phase(c)
+		res := "\\textbf{" + f.formatFloat(cmplx.Abs(c), precision) + "}\\angle "
+		if f.settings.Radians {
+			res += "\\textbf{" + f.formatFloat(angle, precision) + "}"
+		} else {
+			res += "\\textbf{" + f.formatFloat(angle/math.Pi*180, precision) + "}^{\\circ}"
+		}
+		if precision == -1 {
+			return f.FormatParenthesie(res)
+		}
+		return res
+	}
+	re, im := f.formatFloat(real(c), precision), f.formatFloat(math.Abs(imag(c)), precision)
+	imaginary := "\\textbf{" + im + "}" + f.settings.Imaginary
+	if im == "1" {
+		imaginary = "\\textbf{" + f.settings.Imaginary + "}"
+	}
+	switch {
+	case im == "0":
+		return "\\textbf{" + re + "}"
+	case re == "0" && imag(c) < 0:
+		return "-" + imaginary
+	case re == "0":
+		return imaginary
+	}
+	sign := "+"
+	if imag(c) < 0 {
+		sign = "-"
 	}
-	fNum := strings.ReplaceAll(fmt.Sprintf("%v", num), ".", ",")
-	return fmt.Sprintf("\\textbf{%v}\\text{\\scriptsize{%v}}%v", fNum, unit, comment)
+	res := "\\textbf{" + re + "}" + sign + imaginary
+	if precision == -1 {
+		return f.FormatParenthesie(res)
+	}
+	return res
 }
 
 func (f *formatter) FormatVar(name string) string {
-	panic("not implemented") // TODO: Implement
+	// braces so it can not become part of a command like \cdot
+	if len(name) == 1 {
+		return "{" + name + "}"
+	}
+	return "\\mathit{" + name + "}"
 }
 
 func (f *formatter) FormatParenthesie(expr string) string {
 	return "(" + expr + ")"
 }
+
+func (f *formatter) formatItems(list syntax.List, precision int, sep string) string {
+	items := make([]string, len(list))
+	for i, n := range list {
+		items[i] = f.formatFloat(n, precision)
+	}
+	return strings.Join(items, sep)
+}
+
+func (f *formatter) formatTable(list syntax.List, precision int) string {
+	cols := min(len(list), tableWidth)
+	if cols == 0 {
+		return "\\{\\}"
+	}
+	var sb strings.Builder
+	sb.WriteString("\\begin{array}{|" + strings.Repeat("c|", cols) + "}\\hline ")
+	for i := 0; i < len(list); i += tableWidth {
+		row := list[i:min(i+tableWidth, len(list))]
+		sb.WriteString(f.formatItems(row, precision, " & "))
+		sb.WriteString(strings.Repeat(" & ", cols-len(row)))
+		sb.WriteString("\\\\\\hline ")
+	}
+	sb.WriteString("\\end{array}")
+	return sb.String()
+}
+
+// bounds of intervals are rounded outwards, so the rounded interval still contains the result.
+// They are first rounded to a few more decimals, so tiny rounding errors do not make 2.5 into 2.49
+func (f *formatter) formatBound(num float64, precision int, round func(float64) float64) string {
+	if precision == -1 {
+		return "\\textbf{" + f.formatFloat(num, precision) + "}"
+	}
+	amt := math.Pow10(precision)
+	num = round(math.Round(num*amt*1e6)/1e6) / amt
+	// no -0 for bounds that are rounded up to 0
+	if num == 0 {
+		num = 0
+	}
+	return "\\textbf{" + strings.ReplaceAll(fmt.Sprintf("%v", num), ".", f.locale.DecimalSeparator) + "}"
+}
+
+func (f *formatter) formatFloat(num float64, precision int) string {
+	if precision == -1 {
+		precision = 10
+	}
+	amt := math.Pow10(precision)
+	num = math.Round(num*amt) / amt
+	return strings.ReplaceAll(fmt.Sprintf("%v", num), ".", f.locale.DecimalSeparator)
+}
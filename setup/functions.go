@@ -2,15 +2,17 @@ package setup
 
 import (
 	"math"
+	"math/cmplx"
 
 	"github.com/eliiasg/mdcalc/syntax"
 )
 
-func genFunctions() map[string]map[int]syntax.Function {
-	return map[string]map[int]syntax.Function{
+func genFunctions(locale Locale) map[string]map[int]syntax.Function {
+	functions := map[string]map[int]syntax.Function{
 		// functions
 		"floor": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return math.Floor(args[0]), nil
 				},
@@ -19,6 +21,7 @@ func genFunctions() map[string]map[int]syntax.Function {
 		},
 		"ceil": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return math.Ceil(args[0]), nil
 				},
@@ -27,22 +30,31 @@ func genFunctions() map[string]map[int]syntax.Function {
 		},
 		"abs": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return math.Abs(args[0]), nil
 				},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return complex(cmplx.Abs(args[0]), 0), nil
+				},
 				Latex: "\\lvert@0\\rvert",
 			},
 		},
 		"sqrt": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.RootUnit, Degree: 2},
 				Execute: func(args []float64) (float64, error) {
 					return math.Sqrt(args[0]), nil
 				},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return cmplx.Sqrt(args[0]), nil
+				},
 				Latex: "\\sqrt{@0}",
 			},
 		},
 		"root": {
 			2: {
+				Unit: syntax.UnitRule{Kind: syntax.RootUnit, Param: 1},
 				Execute: func(args []float64) (float64, error) {
 					return math.Pow(args[1], 1/args[0]), nil
 				},
@@ -51,6 +63,7 @@ func genFunctions() map[string]map[int]syntax.Function {
 		},
 		"log10": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.RequiresDimensionless},
 				Execute: func(args []float64) (float64, error) {
 					return math.Log10(args[0]), nil
 				},
@@ -59,68 +72,299 @@ func genFunctions() map[string]map[int]syntax.Function {
 		},
 		"log": {
 			2: {
+				Unit: syntax.UnitRule{Kind: syntax.RequiresDimensionless},
 				Execute: func(args []float64) (float64, error) {
 					return math.Log2(args[1]) / math.Log2(args[0]), nil
 				},
 				Latex: "\\log_{@0} @1",
 			},
 		},
-		"sin": {
+		"ln": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.RequiresDimensionless},
 				Execute: func(args []float64) (float64, error) {
-					return math.Sin(args[0] * math.Pi / 180), nil
+					return math.Log(args[0]), nil
+				},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return cmplx.Log(args[0]), nil
 				},
-				Latex: "\\sin @0",
+				Latex: "\\ln @0",
+			},
+		},
+		"sin": {
+			1: {
+				Special: trig{fn: math.Sin, latex: "\\sin"},
 			},
 		},
 		"cos": {
 			1: {
-				Execute: func(args []float64) (float64, error) {
-					return math.Cos(args[0] * math.Pi / 180), nil
-				},
-				Latex: "\\cos @0",
+				Special: trig{fn: math.Cos, latex: "\\cos"},
 			},
 		},
 		"tan": {
 			1: {
-				Execute: func(args []float64) (float64, error) {
-					return math.Tan(args[0] * math.Pi / 180), nil
-				},
-				Latex: "\\tan @0",
+				Special: trig{fn: math.Tan, latex: "\\tan"},
 			},
 		},
 		"asin": {
 			1: {
-				Execute: func(args []float64) (float64, error) {
-					return math.Asin(args[0]) / math.Pi * 180, nil
-				},
-				Latex: "\\arcsin @0",
+				Special: trig{fn: math.Asin, latex: "\\arcsin", inverse: true},
 			},
 		},
 		"acos": {
 			1: {
-				Execute: func(args []float64) (float64, error) {
-					return math.Acos(args[0]) / math.Pi * 180, nil
-				},
-				Latex: "\\arccos @0",
+				Special: trig{fn: math.Acos, latex: "\\arccos", inverse: true},
 			},
 		},
 		"atan": {
 			1: {
-				Execute: func(args []float64) (float64, error) {
-					return math.Atan(args[0]) / math.Pi * 180, nil
-				},
-				Latex: "\\arctan @0",
+				Special: trig{fn: math.Atan, latex: "\\arctan", inverse: true},
 			},
 		},
 		"mod": {
 			2: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return math.Mod(args[0], args[1]), nil
 				},
 				Latex: "@0 \\mod @1",
 			},
 		},
+		// complex numbers
+		"re": {
+			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return complex(real(args[0]), 0), nil
+				},
+				Latex: "\\operatorname{Re}(@0)",
+			},
+		},
+		"im": {
+			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return complex(imag(args[0]), 0), nil
+				},
+				Latex: "\\operatorname{Im}(@0)",
+			},
+		},
+		"conj": {
+			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return cmplx.Conj(args[0]), nil
+				},
+				Latex: "\\overline{@0}",
+			},
+		},
+		"arg": {
+			1: {
+				Special: argument{},
+			},
+		},
+		"polar": {
+			2: {
+				Special: polar{},
+			},
+		},
+		// linear algebra, vectors are matrices with one column like [1; 2; 3]
+		"dot": {
+			2: {
+				ExecuteValue: dot,
+				Latex:        "@0\\cdot @1",
+				Steps:        dotSteps,
+			},
+		},
+		"cross": {
+			2: {
+				ExecuteValue: cross,
+				Latex:        "@0\\times @1",
+				Steps:        crossSteps,
+			},
+		},
+		"det": {
+			1: {
+				ExecuteValue: det,
+				Latex:        "\\det(@0)",
+				Steps:        detSteps,
+			},
+		},
+		"inv": {
+			1: {
+				ExecuteValue: inv,
+				Latex:        "@0^{-1}",
+			},
+		},
+		"transpose": {
+			1: {
+				ExecuteValue: transposed,
+				Latex:        "@0^{T}",
+			},
+		},
+		"linsolve": {
+			2: {
+				ExecuteValue: linsolve,
+				Latex:        "@0^{-1}\\cdot @1",
+			},
+		},
+		// statistics
+		"sum": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listSum),
+				Latex:        "\\sum @0",
+				Steps:        sumSteps,
+			},
+			4: {
+				Special: series{},
+			},
+		},
+		"prod": {
+			4: {
+				Special: series{product: true},
+			},
+		},
+		"if": {
+			3: {
+				Special: conditional{locale: locale},
+			},
+			5: {
+				Special: conditional{locale: locale},
+			},
+			7: {
+				Special: conditional{locale: locale},
+			},
+			9: {
+				Special: conditional{locale: locale},
+			},
+		},
+		"solve": {
+			2: {
+				Special: solver{},
+			},
+			3: {
+				Special: solver{},
+			},
+		},
+		"diff": {
+			2: {
+				Special: differentiation{},
+			},
+			3: {
+				Special: differentiation{atPoint: true},
+			},
+		},
+		"integrate": {
+			4: {
+				Special: integral{},
+			},
+		},
+		"mean": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listMean),
+				Latex:        "\\overline{@0}",
+				Steps:        meanSteps,
+			},
+		},
+		"median": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listMedian),
+				Latex:        "\\text{median}(@0)",
+				Steps:        medianSteps,
+			},
+		},
+		"var": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.PowerUnit, Degree: 2},
+				ExecuteValue: listFunction(listVariance),
+				Latex:        "\\text{var}(@0)",
+				Steps:        varianceSteps,
+			},
+		},
+		"stdev": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listStdev),
+				Latex:        "s_{@0}",
+				Steps:        stdevSteps,
+			},
+		},
+		"quartiles": {
+			1: {
+				ExecuteValue: listFunction(listQuartiles),
+				Latex:        "\\text{kvartiler}(@0)",
+			},
+		},
+		"min": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listMin),
+				Latex:        "\\min(@0)",
+			},
+		},
+		"max": {
+			1: {
+				Unit:         syntax.UnitRule{Kind: syntax.SameUnit},
+				ExecuteValue: listFunction(listMax),
+				Latex:        "\\max(@0)",
+			},
+		},
+		"count": {
+			1: {
+				ExecuteValue: listFunction(listCount),
+				Latex:        "n_{@0}",
+			},
+		},
+		// finance, r is the interest rate per period like 0.05 and n the amount of periods
+		"fv": {
+			3: {
+				Execute: futureValue,
+				Latex:   "@(0)\\cdot(1+@1)^{@2}",
+				Unit:    syntax.UnitRule{Kind: syntax.SameUnit},
+			},
+		},
+		"pv": {
+			3: {
+				Execute: presentValue,
+				Latex:   "@(0)\\cdot(1+@1)^{-@(2)}",
+				Unit:    syntax.UnitRule{Kind: syntax.SameUnit},
+			},
+		},
+		"interest": {
+			3: {
+				Execute: interest,
+				Latex:   "@(0)\\cdot(1+@1)^{@2}-@(0)",
+				Unit:    syntax.UnitRule{Kind: syntax.SameUnit},
+			},
+		},
+		"ydelse": {
+			3: {
+				Execute: annuityPayment,
+				Latex:   "@(0)\\cdot\\dfrac{@1}{1-(1+@1)^{-@(2)}}",
+				Unit:    syntax.UnitRule{Kind: syntax.SameUnit},
+			},
+		},
+		"periods": {
+			3: {
+				Execute: periods,
+				Latex:   "\\dfrac{\\log\\left(\\dfrac{@2}{@0}\\right)}{\\log(1+@1)}",
+			},
+		},
+		"effective": {
+			2: {
+				Execute: effectiveRate,
+				Latex:   "\\left(1+\\dfrac{@0}{@1}\\right)^{@1}-1",
+			},
+		},
+		"change": {
+			2: {
+				Execute: change,
+				Latex:   "\\dfrac{@1-@(0)}{@0}",
+			},
+		},
 		// symbols
 		"pi": {
 			0: {
@@ -141,19 +385,38 @@ func genFunctions() map[string]map[int]syntax.Function {
 		// util / formatting
 		"par": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return args[0], nil
 				},
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return args[0], nil
+				},
 				Latex: "(@0)",
 			},
 		},
 		"neg": {
 			1: {
+				Unit: syntax.UnitRule{Kind: syntax.SameUnit},
 				Execute: func(args []float64) (float64, error) {
 					return -args[0], nil
 				},
-				Latex: "-@0",
+				ExecuteComplex: func(args []complex128) (complex128, error) {
+					return -args[0], nil
+				},
+				Latex: "-@(0)",
 			},
 		},
 	}
+	// functions that are increasing or decreasing everywhere support intervals
+	for _, name := range []string{"floor", "ceil", "sqrt", "log10", "ln", "par", "neg"} {
+		for n, f := range functions[name] {
+			f.ExecuteInterval = monotonic(f.Execute)
+			functions[name][n] = f
+		}
+	}
+	abs := functions["abs"][1]
+	abs.ExecuteInterval = intervalAbs
+	functions["abs"][1] = abs
+	return functions
 }
@@ -0,0 +1,95 @@
+package setup
+
+import (
+	"errors"
+	"math"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// widens the interval by the smallest possible amount, so rounding errors can not make it too narrow
+func outward(lo, hi float64) syntax.Interval {
+	return syntax.Interval{Lo: math.Nextafter(lo, math.Inf(-1)), Hi: math.Nextafter(hi, math.Inf(1))}
+}
+
+// the smallest interval containing every value
+func hull(values ...float64) (syntax.Interval, error) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) {
+			return syntax.Interval{}, errors.New("the result is not defined for the whole interval")
+		}
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return outward(lo, hi), nil
+}
+
+func intervalAdd(l, r syntax.Interval) (syntax.Interval, error) {
+	return outward(l.Lo+r.Lo, l.Hi+r.Hi), nil
+}
+
+func intervalSub(l, r syntax.Interval) (syntax.Interval, error) {
+	return outward(l.Lo-r.Hi, l.Hi-r.Lo), nil
+}
+
+func intervalMul(l, r syntax.Interval) (syntax.Interval, error) {
+	return hull(l.Lo*r.Lo, l.Lo*r.Hi, l.Hi*r.Lo, l.Hi*r.Hi)
+}
+
+func intervalDiv(l, r syntax.Interval) (syntax.Interval, error) {
+	if r.Lo <= 0 && r.Hi >= 0 {
+		return syntax.Interval{}, errors.New("divide by an interval containing 0")
+	}
+	return hull(l.Lo/r.Lo, l.Lo/r.Hi, l.Hi/r.Lo, l.Hi/r.Hi)
+}
+
+func intervalPow(l, r syntax.Interval) (syntax.Interval, error) {
+	n := r.Lo
+	if r.Lo == r.Hi && n == math.Trunc(n) {
+		if n < 0 && l.Lo <= 0 && l.Hi >= 0 {
+			return syntax.Interval{}, errors.New("divide by an interval containing 0")
+		}
+		a, b := math.Pow(l.Lo, n), math.Pow(l.Hi, n)
+		// even powers have their smallest value at 0
+		if int(n)%2 == 0 && n > 0 && l.Lo < 0 && l.Hi > 0 {
+			return hull(0, a, b)
+		}
+		return hull(a, b)
+	}
+	if l.Lo < 0 {
+		return syntax.Interval{}, errors.New("only whole powers of intervals with negative numbers are supported")
+	}
+	// x^y is monotonic in both x and y when x is positive
+	return hull(math.Pow(l.Lo, r.Lo), math.Pow(l.Lo, r.Hi), math.Pow(l.Hi, r.Lo), math.Pow(l.Hi, r.Hi))
+}
+
+// for functions that are increasing or decreasing in every parameter, so the result is between the results at the bounds
+func monotonic(f func([]float64) (float64, error)) func([]syntax.Interval) (syntax.Interval, error) {
+	return func(args []syntax.Interval) (syntax.Interval, error) {
+		values := make([]float64, 0, 1<<len(args))
+		x := make([]float64, len(args))
+		// every combination of lower and upper bounds
+		for mask := 0; mask < 1<<len(args); mask++ {
+			for i, arg := range args {
+				x[i] = arg.Lo
+				if mask&(1<<i) != 0 {
+					x[i] = arg.Hi
+				}
+			}
+			res, err := f(x)
+			if err != nil {
+				return syntax.Interval{}, err
+			}
+			values = append(values, res)
+		}
+		return hull(values...)
+	}
+}
+
+func intervalAbs(args []syntax.Interval) (syntax.Interval, error) {
+	x := args[0]
+	if x.Lo <= 0 && x.Hi >= 0 {
+		return hull(0, -x.Lo, x.Hi)
+	}
+	return hull(math.Abs(x.Lo), math.Abs(x.Hi))
+}
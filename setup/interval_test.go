@@ -0,0 +1,61 @@
+package setup
+
+import (
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+func TestIntervals(t *testing.T) {
+	tests := []struct {
+		code   string
+		lo, hi float64
+		err    string
+	}{
+		{code: "[1, 2] + [3, 4]", lo: 4, hi: 6},
+		{code: "[1, 2] - [3, 4]", lo: -3, hi: -1},
+		{code: "[-1, 2] * [3, 4]", lo: -4, hi: 8},
+		{code: "[1, 2] / [4, 8]", lo: 0.125, hi: 0.5},
+		{code: "[-1, 1] * 2", lo: -2, hi: 2},
+		{code: "[-2, 3]^2", lo: 0, hi: 9},
+		{code: "[2, 3]^-1", lo: 1.0 / 3, hi: 0.5},
+		{code: "[4, 9]^0.5", lo: 2, hi: 3},
+		{code: "abs([-2, 1])", lo: 0, hi: 2},
+		{code: "sqrt([4, 9])", lo: 2, hi: 3},
+		{code: "neg([1, 2])", lo: -2, hi: -1},
+		{code: "-[1, 2]", lo: -2, hi: -1},
+		{code: "[2.5, 2.7] / [-1, 1]", err: "divide by an interval containing 0"},
+		{code: "[2.5, 2.7] / [0, 1]", err: "divide by an interval containing 0"},
+		{code: "[-1, 1]^-2", err: "divide by an interval containing 0"},
+		{code: "[-1, 1]^0.5", err: "only whole powers"},
+		{code: "sqrt([-4, 9])", err: "not defined for the whole interval"},
+	}
+	for _, test := range tests {
+		res, err := calculate(t, test.code, "intervals on")
+		if checkError(t, test.code, err, test.err) || test.err != "" {
+			continue
+		}
+		in, ok := res.(syntax.Interval)
+		if !ok {
+			t.Errorf("%v: expected an interval, got %v", test.code, res)
+			continue
+		}
+		// the bounds are widened a little, but must still contain the exact bounds
+		if in.Lo > test.lo || in.Hi < test.hi || !near(in.Lo, test.lo, 1e-12) || !near(in.Hi, test.hi, 1e-12) {
+			t.Errorf("%v: expected [%v, %v], got [%v, %v]", test.code, test.lo, test.hi, in.Lo, in.Hi)
+		}
+	}
+}
+
+func TestIntervalDiv(t *testing.T) {
+	if _, err := intervalDiv(syntax.Interval{Lo: 2.5, Hi: 2.7}, syntax.Interval{Lo: -1, Hi: 1}); err == nil {
+		t.Error("dividing by an interval containing 0 should be an error")
+	}
+	res, err := intervalDiv(syntax.Interval{Lo: 1, Hi: 2}, syntax.Interval{Lo: -4, Hi: -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Lo > -1 || res.Hi < -0.25 {
+		t.Errorf("expected [-1, -0.25], got [%v, %v]", res.Lo, res.Hi)
+	}
+}
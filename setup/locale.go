@@ -0,0 +1,29 @@
+package setup
+
+// The language of the rendered output
+type Locale struct {
+	// between the whole and the decimal part of numbers
+	DecimalSeparator string
+	// label of the boxed answers of C! lines
+	Answer string
+	// headings of the answer table
+	AnswerTable [4]string
+	// for piecewise functions, like if x < 0 and otherwise
+	If, Otherwise string
+}
+
+var Danish = Locale{
+	DecimalSeparator: ",",
+	Answer:           "Svar",
+	AnswerTable:      [4]string{"Delopgave", "Beskrivelse", "Værdi", "Enhed"},
+	If:               "hvis",
+	Otherwise:        "ellers",
+}
+
+var English = Locale{
+	DecimalSeparator: ".",
+	Answer:           "Answer",
+	AnswerTable:      [4]string{"Subproblem", "Description", "Value", "Unit"},
+	If:               "if",
+	Otherwise:        "otherwise",
+}
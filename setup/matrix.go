@@ -0,0 +1,567 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// largest amount of rows and columns where calculations are shown element by element
+const maxMatrixSteps = 3
+
+// higher powers of a matrix are almost always a mistake, and the entries overflow anyway
+const maxMatrixPower = 1 << 20
+
+// matrices as they are, lists become vectors and numbers are not matrices
+func toMatrix(v syntax.Value) (syntax.Matrix, bool) {
+	switch m := v.(type) {
+	case syntax.Matrix:
+		return m, true
+	case syntax.List:
+		res := make(syntax.Matrix, len(m))
+		for i, n := range m {
+			res[i] = []float64{n}
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+func matrixArgs(l, r syntax.Value) (syntax.Matrix, syntax.Matrix, error) {
+	lm, lok := toMatrix(l)
+	rm, rok := toMatrix(r)
+	if !lok || !rok {
+		return nil, nil, errors.New("expected two matrices")
+	}
+	return lm, rm, nil
+}
+
+func size(m syntax.Matrix) (int, int) {
+	if len(m) == 0 {
+		return 0, 0
+	}
+	return len(m), len(m[0])
+}
+
+func newMatrix(rows, cols int) syntax.Matrix {
+	res := make(syntax.Matrix, rows)
+	for i := range res {
+		res[i] = make([]float64, cols)
+	}
+	return res
+}
+
+func identity(n int) syntax.Matrix {
+	res := newMatrix(n, n)
+	for i := range res {
+		res[i][i] = 1
+	}
+	return res
+}
+
+// a number as a list or matrix with the size of other, so it is used with every item
+func broadcast(v, other syntax.Value) syntax.Value {
+	k, ok := v.(float64)
+	if !ok {
+		return v
+	}
+	switch o := other.(type) {
+	case syntax.List:
+		res := make(syntax.List, len(o))
+		for i := range res {
+			res[i] = k
+		}
+		return res
+	case syntax.Matrix:
+		res := newMatrix(size(o))
+		for i := range res {
+			for j := range res[i] {
+				res[i][j] = k
+			}
+		}
+		return res
+	}
+	return v
+}
+
+// two lists of the same length, numbers are used for every item of the other list
+func listArgs(l, r syntax.Value) (syntax.List, syntax.List, bool, error) {
+	ll, lok := broadcast(l, r).(syntax.List)
+	rl, rok := broadcast(r, l).(syntax.List)
+	if !lok || !rok {
+		return nil, nil, false, nil
+	}
+	if len(ll) != len(rl) {
+		return nil, nil, true, fmt.Errorf("lists must have the same length, got %v and %v", len(ll), len(rl))
+	}
+	return ll, rl, true, nil
+}
+
+// element by element, for + and -, lists stay lists and numbers are used for every item
+func elementwise(fn func(a, b float64) float64) func(l, r syntax.Value) (syntax.Value, error) {
+	return func(l, r syntax.Value) (syntax.Value, error) {
+		if ll, rl, ok, err := listArgs(l, r); ok {
+			if err != nil {
+				return math.NaN(), err
+			}
+			res := make(syntax.List, len(ll))
+			for i := range res {
+				res[i] = fn(ll[i], rl[i])
+			}
+			return res, nil
+		}
+		lm, rm, err := matrixArgs(broadcast(l, r), broadcast(r, l))
+		if err != nil {
+			return math.NaN(), err
+		}
+		rows, cols := size(lm)
+		if rr, rc := size(rm); rr != rows || rc != cols {
+			return math.NaN(), fmt.Errorf("matrices must have the same size, got %vx%v and %vx%v", rows, cols, rr, rc)
+		}
+		res := newMatrix(rows, cols)
+		for i := range res {
+			for j := range res[i] {
+				res[i][j] = fn(lm[i][j], rm[i][j])
+			}
+		}
+		return res, nil
+	}
+}
+
+func scaleList(list syntax.List, k float64) syntax.List {
+	res := make(syntax.List, len(list))
+	for i, n := range list {
+		res[i] = n * k
+	}
+	return res
+}
+
+func scale(m syntax.Matrix, k float64) syntax.Matrix {
+	res := newMatrix(size(m))
+	for i := range res {
+		for j := range res[i] {
+			res[i][j] = m[i][j] * k
+		}
+	}
+	return res
+}
+
+func matrixMul(l, r syntax.Value) (syntax.Value, error) {
+	if _, ok := l.(float64); ok {
+		l, r = r, l
+	}
+	if k, ok := r.(float64); ok {
+		if list, ok := l.(syntax.List); ok {
+			return scaleList(list, k), nil
+		}
+		if m, ok := toMatrix(l); ok {
+			return scale(m, k), nil
+		}
+	}
+	lm, rm, err := matrixArgs(l, r)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return product(lm, rm)
+}
+
+func product(l, r syntax.Matrix) (syntax.Value, error) {
+	rows, n := size(l)
+	rr, cols := size(r)
+	if n != rr {
+		return math.NaN(), fmt.Errorf("can not multiply a %vx%v matrix with a %vx%v matrix", rows, n, rr, cols)
+	}
+	res := newMatrix(rows, cols)
+	for i := range res {
+		for j := range res[i] {
+			for k := 0; k < n; k++ {
+				res[i][j] += l[i][k] * r[k][j]
+			}
+		}
+	}
+	return res, nil
+}
+
+func matrixDiv(l, r syntax.Value) (syntax.Value, error) {
+	k, ok := r.(float64)
+	m, mok := toMatrix(l)
+	if !ok || !mok {
+		return math.NaN(), errors.New("matrices can only be divided by a number, use inv for the inverse")
+	}
+	if k == 0 {
+		return math.NaN(), errors.New("divide by zero")
+	}
+	if list, ok := l.(syntax.List); ok {
+		return scaleList(list, 1/k), nil
+	}
+	return scale(m, 1/k), nil
+}
+
+// integer powers of square matrices, negative powers use the inverse
+func matrixPow(l, r syntax.Value) (syntax.Value, error) {
+	m, ok := toMatrix(l)
+	n, nok := r.(float64)
+	if !ok || !nok || n != math.Trunc(n) {
+		return math.NaN(), errors.New("matrices can only be raised to a whole number")
+	}
+	if math.Abs(n) > maxMatrixPower {
+		return math.NaN(), fmt.Errorf("matrices can only be raised to powers up to %v", maxMatrixPower)
+	}
+	rows, cols := size(m)
+	if rows != cols {
+		return math.NaN(), errors.New("only square matrices can be raised to a power")
+	}
+	if n < 0 {
+		inv, err := inverse(m)
+		if err != nil {
+			return math.NaN(), err
+		}
+		m, n = inv, -n
+	}
+	// exponentiation by squaring
+	res := identity(rows)
+	for k := int(n); k > 0; k /= 2 {
+		if k%2 == 1 {
+			p, _ := product(res, m)
+			res = p.(syntax.Matrix)
+		}
+		if k > 1 {
+			p, _ := product(m, m)
+			m = p.(syntax.Matrix)
+		}
+	}
+	return res, nil
+}
+
+// solves m*x = b with gaussian elimination, b can have more than one column
+func gauss(m, b syntax.Matrix) (syntax.Matrix, error) {
+	n, cols := size(m)
+	if n != cols {
+		return nil, errors.New("the matrix must be square")
+	}
+	if br, _ := size(b); br != n {
+		return nil, fmt.Errorf("expected %v rows on the right side, got %v", n, br)
+	}
+	a := make(syntax.Matrix, n)
+	for i := range a {
+		a[i] = append(append([]float64{}, m[i]...), b[i]...)
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(a[i][col]) > math.Abs(a[pivot][col]) {
+				pivot = i
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, errors.New("the matrix is singular (its determinant is 0)")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for i := 0; i < n; i++ {
+			if i == col {
+				continue
+			}
+			f := a[i][col] / a[col][col]
+			for j := col; j < len(a[i]); j++ {
+				a[i][j] -= f * a[col][j]
+			}
+		}
+	}
+	res := newMatrix(n, len(a[0])-n)
+	for i := range res {
+		for j := range res[i] {
+			res[i][j] = a[i][n+j] / a[i][i]
+		}
+	}
+	return res, nil
+}
+
+func inverse(m syntax.Matrix) (syntax.Matrix, error) {
+	n, _ := size(m)
+	return gauss(m, identity(n))
+}
+
+func determinant(m syntax.Matrix) (float64, error) {
+	n, cols := size(m)
+	if n != cols {
+		return math.NaN(), errors.New("only square matrices have a determinant")
+	}
+	a := make(syntax.Matrix, n)
+	for i := range a {
+		a[i] = append([]float64{}, m[i]...)
+	}
+	det := 1.0
+	for col := 0; col < n; col++ {
+		pivot := col
+		for i := col + 1; i < n; i++ {
+			if math.Abs(a[i][col]) > math.Abs(a[pivot][col]) {
+				pivot = i
+			}
+		}
+		if a[pivot][col] == 0 {
+			return 0, nil
+		}
+		if pivot != col {
+			a[col], a[pivot] = a[pivot], a[col]
+			det = -det
+		}
+		det *= a[col][col]
+		for i := col + 1; i < n; i++ {
+			f := a[i][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[i][j] -= f * a[col][j]
+			}
+		}
+	}
+	return det, nil
+}
+
+func transpose(m syntax.Matrix) syntax.Matrix {
+	rows, cols := size(m)
+	res := newMatrix(cols, rows)
+	for i := range m {
+		for j := range m[i] {
+			res[j][i] = m[i][j]
+		}
+	}
+	return res
+}
+
+// the items of a vector, which is a matrix with one row or one column
+func vector(v syntax.Value) ([]float64, error) {
+	m, ok := toMatrix(v)
+	if !ok {
+		return nil, fmt.Errorf("expected a vector, got %v", syntax.TypeName(v))
+	}
+	rows, cols := size(m)
+	if cols == 1 {
+		return transpose(m)[0], nil
+	}
+	if rows == 1 {
+		return m[0], nil
+	}
+	return nil, errors.New("expected a vector, got a matrix")
+}
+
+func matrixParam(v syntax.Value) (syntax.Matrix, error) {
+	m, ok := toMatrix(v)
+	if !ok {
+		return nil, fmt.Errorf("expected a matrix, got %v", syntax.TypeName(v))
+	}
+	return m, nil
+}
+
+func dot(args []syntax.Value) (syntax.Value, error) {
+	a, err := vector(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	b, err := vector(args[1])
+	if err != nil {
+		return math.NaN(), err
+	}
+	if len(a) != len(b) {
+		return math.NaN(), errors.New("vectors must have the same length")
+	}
+	res := 0.0
+	for i := range a {
+		res += a[i] * b[i]
+	}
+	return res, nil
+}
+
+func cross(args []syntax.Value) (syntax.Value, error) {
+	a, err := vector(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	b, err := vector(args[1])
+	if err != nil {
+		return math.NaN(), err
+	}
+	if len(a) != 3 || len(b) != 3 {
+		return math.NaN(), errors.New("the cross product is only defined for vectors with 3 items")
+	}
+	return syntax.Matrix{
+		{a[1]*b[2] - a[2]*b[1]},
+		{a[2]*b[0] - a[0]*b[2]},
+		{a[0]*b[1] - a[1]*b[0]},
+	}, nil
+}
+
+func det(args []syntax.Value) (syntax.Value, error) {
+	m, err := matrixParam(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return determinant(m)
+}
+
+func inv(args []syntax.Value) (syntax.Value, error) {
+	m, err := matrixParam(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return inverse(m)
+}
+
+func transposed(args []syntax.Value) (syntax.Value, error) {
+	m, err := matrixParam(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return transpose(m), nil
+}
+
+// linsolve(A, b), x in A*x = b
+func linsolve(args []syntax.Value) (syntax.Value, error) {
+	m, err := matrixParam(args[0])
+	if err != nil {
+		return math.NaN(), err
+	}
+	b, err := matrixParam(args[1])
+	if err != nil {
+		return math.NaN(), err
+	}
+	return gauss(m, b)
+}
+
+func pmatrix(cells [][]string) string {
+	rows := make([]string, len(cells))
+	for i, row := range cells {
+		rows[i] = strings.Join(row, " & ")
+	}
+	return "\\begin{pmatrix}" + strings.Join(rows, "\\\\") + "\\end{pmatrix}"
+}
+
+// numbers in steps, negative numbers get parenthesis so 2+(-3) is not written as 2+-3
+func stepNumber(f syntax.Formatter, n float64) string {
+	res := f.FormatNumber(n, -1, "", "")
+	if n < 0 {
+		return f.FormatParenthesie(res)
+	}
+	return res
+}
+
+func smallMatrix(m syntax.Matrix) bool {
+	rows, cols := size(m)
+	return rows > 0 && rows <= maxMatrixSteps && cols <= maxMatrixSteps
+}
+
+// a matrix where every cell is written with cell
+func stepMatrix(rows, cols int, cell func(i, j int) string) []string {
+	cells := make([][]string, rows)
+	for i := range cells {
+		cells[i] = make([]string, cols)
+		for j := range cells[i] {
+			cells[i][j] = cell(i, j)
+		}
+	}
+	return []string{pmatrix(cells)}
+}
+
+// a list where every item is written with item
+func stepItems(n int, item func(i int) string) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = item(i)
+	}
+	return []string{"\\{" + strings.Join(items, ";\\,") + "\\}"}
+}
+
+func elementwiseSteps(operator string) func(f syntax.Formatter, l, r syntax.Value) []string {
+	return func(f syntax.Formatter, l, r syntax.Value) []string {
+		if ll, rl, ok, err := listArgs(l, r); ok {
+			if err != nil || len(ll) == 0 || len(ll) > maxStepTerms {
+				return nil
+			}
+			return stepItems(len(ll), func(i int) string {
+				return f.FormatNumber(ll[i], -1, "", "") + operator + stepNumber(f, rl[i])
+			})
+		}
+		lm, rm, err := matrixArgs(broadcast(l, r), broadcast(r, l))
+		if err != nil || !smallMatrix(lm) || len(lm) != len(rm) || len(lm[0]) != len(rm[0]) {
+			return nil
+		}
+		return stepMatrix(len(lm), len(lm[0]), func(i, j int) string {
+			return f.FormatNumber(lm[i][j], -1, "", "") + operator + stepNumber(f, rm[i][j])
+		})
+	}
+}
+
+func mulSteps(f syntax.Formatter, l, r syntax.Value) []string {
+	if _, ok := r.(float64); ok {
+		l, r = r, l
+	}
+	if k, ok := l.(float64); ok {
+		if list, ok := r.(syntax.List); ok {
+			if len(list) == 0 || len(list) > maxStepTerms {
+				return nil
+			}
+			return stepItems(len(list), func(i int) string {
+				return stepNumber(f, k) + "\\cdot " + stepNumber(f, list[i])
+			})
+		}
+		m, ok := toMatrix(r)
+		if !ok || !smallMatrix(m) {
+			return nil
+		}
+		return stepMatrix(len(m), len(m[0]), func(i, j int) string {
+			return stepNumber(f, k) + "\\cdot " + stepNumber(f, m[i][j])
+		})
+	}
+	lm, rm, err := matrixArgs(l, r)
+	if err != nil || !smallMatrix(lm) || !smallMatrix(rm) || len(lm[0]) != len(rm) {
+		return nil
+	}
+	return stepMatrix(len(lm), len(rm[0]), func(i, j int) string {
+		terms := make([]string, len(rm))
+		for k := range rm {
+			terms[k] = stepNumber(f, lm[i][k]) + "\\cdot " + stepNumber(f, rm[k][j])
+		}
+		return strings.Join(terms, "+")
+	})
+}
+
+func dotSteps(f syntax.Formatter, args []syntax.Value) []string {
+	a, err := vector(args[0])
+	if err != nil {
+		return nil
+	}
+	b, err := vector(args[1])
+	if err != nil || len(a) != len(b) || len(a) > maxStepTerms {
+		return nil
+	}
+	terms := make([]string, len(a))
+	for i := range a {
+		terms[i] = stepNumber(f, a[i]) + "\\cdot " + stepNumber(f, b[i])
+	}
+	return []string{strings.Join(terms, "+")}
+}
+
+func crossSteps(f syntax.Formatter, args []syntax.Value) []string {
+	a, err := vector(args[0])
+	if err != nil {
+		return nil
+	}
+	b, err := vector(args[1])
+	if err != nil || len(a) != 3 || len(b) != 3 {
+		return nil
+	}
+	return stepMatrix(3, 1, func(i, _ int) string {
+		j, k := (i+1)%3, (i+2)%3
+		return stepNumber(f, a[j]) + "\\cdot " + stepNumber(f, b[k]) + "-" + stepNumber(f, a[k]) + "\\cdot " + stepNumber(f, b[j])
+	})
+}
+
+// ad-bc for 2x2 matrices
+func detSteps(f syntax.Formatter, args []syntax.Value) []string {
+	m, ok := toMatrix(args[0])
+	if rows, cols := size(m); !ok || rows != 2 || cols != 2 {
+		return nil
+	}
+	return []string{stepNumber(f, m[0][0]) + "\\cdot " + stepNumber(f, m[1][1]) + "-" + stepNumber(f, m[0][1]) + "\\cdot " + stepNumber(f, m[1][0])}
+}
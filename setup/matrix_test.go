@@ -0,0 +1,48 @@
+package setup
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+func TestMatrixOperators(t *testing.T) {
+	tests := []struct {
+		code string
+		want syntax.Value
+		err  string
+	}{
+		{code: "[1,2,3]*2", want: syntax.List{2, 4, 6}},
+		{code: "2*[1,2,3]", want: syntax.List{2, 4, 6}},
+		{code: "[1,2,3]/2", want: syntax.List{0.5, 1, 1.5}},
+		{code: "[1,2,3]+1", want: syntax.List{2, 3, 4}},
+		{code: "1-[1,2,3]", want: syntax.List{0, -1, -2}},
+		{code: "[1,2,3]-[3,2,1]", want: syntax.List{-2, 0, 2}},
+		{code: "mean([1,2,3]*2)", want: 4.0},
+		{code: "[1,2]+[1,2,3]", err: "lists must have the same length"},
+		{code: "[1,2;3,4]+1", want: syntax.Matrix{{2, 3}, {4, 5}}},
+		{code: "[1,2;3,4]+[1,1;1,1]", want: syntax.Matrix{{2, 3}, {4, 5}}},
+		{code: "[1,2;3,4]+[1,1]", err: "matrices must have the same size"},
+		{code: "[1,2;3,4]*[1,2]", want: syntax.Matrix{{5}, {11}}},
+		{code: "[1,2;3,4]*[1,0;0,1]", want: syntax.Matrix{{1, 2}, {3, 4}}},
+		{code: "[1,2,3]*[1,2,3]", err: "can not multiply a 3x1 matrix with a 3x1 matrix"},
+		{code: "[2,4;6,8]/2", want: syntax.Matrix{{1, 2}, {3, 4}}},
+		{code: "[1,2;3,4]/[1,2;3,4]", err: "use inv for the inverse"},
+		{code: "[1,1;0,1]^3", want: syntax.Matrix{{1, 3}, {0, 1}}},
+		{code: "[1,2;3,4]^0", want: syntax.Matrix{{1, 0}, {0, 1}}},
+		{code: "[2,0;0,4]^-1", want: syntax.Matrix{{0.5, 0}, {0, 0.25}}},
+		{code: "[1,2;2,4]^-1", err: "the matrix is singular"},
+		{code: "[1,2;3,4]^0.5", err: "only be raised to a whole number"},
+		{code: "[1,2,3;4,5,6]^2", err: "only square matrices"},
+	}
+	for _, test := range tests {
+		res, err := calculate(t, test.code)
+		if checkError(t, test.code, err, test.err) || test.err != "" {
+			continue
+		}
+		if !reflect.DeepEqual(res, test.want) {
+			t.Errorf("%v: expected %v, got %v", test.code, test.want, res)
+		}
+	}
+}
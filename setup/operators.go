@@ -3,6 +3,7 @@ package setup
 import (
 	"errors"
 	"math"
+	"math/cmplx"
 
 	"github.com/eliiasg/mdcalc/syntax"
 )
@@ -14,12 +15,48 @@ func div(l, r float64) (float64, error) {
 	return l / r, nil
 }
 
+// 9.81±0.02
+func uncertain(l, r syntax.Value) (syntax.Value, error) {
+	value, err := syntax.Number(l)
+	if err != nil {
+		return math.NaN(), err
+	}
+	uncertainty, err := syntax.Number(r)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return syntax.Uncertain{Value: value, Uncertainty: math.Abs(uncertainty)}, nil
+}
+
+// true is 1 and false is 0
+func comparison(f func(l, r float64) bool) func(l, r float64) (float64, error) {
+	return func(l, r float64) (float64, error) {
+		if f(l, r) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+}
+
+func complexDiv(l, r complex128) (complex128, error) {
+	if r == 0 {
+		return cmplx.NaN(), errors.New("divide by zero")
+	}
+	return l / r, nil
+}
+
 func genOperators() map[string]syntax.Operator {
 	return map[string]syntax.Operator{
 		"*": {
 			Execute: func(l, r float64) (float64, error) {
 				return l * r, nil
 			},
+			ExecuteComplex: func(l, r complex128) (complex128, error) {
+				return l * r, nil
+			},
+			ExecuteValue:     matrixMul,
+			ExecuteInterval:  intervalMul,
+			Steps:            mulSteps,
 			Latex:            "@l\\cdot@r",
 			ParenthesisLeft:  true,
 			ParenthesisRight: true,
@@ -27,6 +64,9 @@ func genOperators() map[string]syntax.Operator {
 		},
 		"/": {
 			Execute:          div,
+			ExecuteComplex:   complexDiv,
+			ExecuteValue:     matrixDiv,
+			ExecuteInterval:  intervalDiv,
 			Latex:            "\\dfrac{@l}{@r}",
 			ParenthesisLeft:  false,
 			ParenthesisRight: false,
@@ -34,6 +74,8 @@ func genOperators() map[string]syntax.Operator {
 		},
 		"%": {
 			Execute:          div,
+			ExecuteComplex:   complexDiv,
+			ExecuteInterval:  intervalDiv,
 			Latex:            "@l\\div@r",
 			ParenthesisLeft:  true,
 			ParenthesisRight: true,
@@ -43,6 +85,9 @@ func genOperators() map[string]syntax.Operator {
 			Execute: func(l, r float64) (float64, error) {
 				return math.Pow(l, r), nil
 			},
+			ExecuteComplex:   complexPow,
+			ExecuteValue:     matrixPow,
+			ExecuteInterval:  intervalPow,
 			Latex:            "@l^{@r}",
 			ParenthesisLeft:  true,
 			ParenthesisRight: false,
@@ -52,15 +97,91 @@ func genOperators() map[string]syntax.Operator {
 			Execute: func(l, r float64) (float64, error) {
 				return l + r, nil
 			},
+			ExecuteComplex: func(l, r complex128) (complex128, error) {
+				return l + r, nil
+			},
+			ExecuteValue:     elementwise(func(a, b float64) float64 { return a + b }),
+			ExecuteInterval:  intervalAdd,
+			Steps:            elementwiseSteps("+"),
 			Latex:            "@l+@r",
 			ParenthesisLeft:  true,
 			ParenthesisRight: true,
 			OrderMatters:     false,
 		},
+		"±": {
+			ExecuteValue:     uncertain,
+			Latex:            "(@l\\pm @r)",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     false,
+		},
+		"<": {
+			Execute:          comparison(func(l, r float64) bool { return l < r }),
+			Latex:            "@l<@r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     true,
+			Unitless:         true,
+		},
+		"<=": {
+			Execute:          comparison(func(l, r float64) bool { return l <= r }),
+			Latex:            "@l\\leq @r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     true,
+			Unitless:         true,
+		},
+		">": {
+			Execute:          comparison(func(l, r float64) bool { return l > r }),
+			Latex:            "@l>@r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     true,
+			Unitless:         true,
+		},
+		">=": {
+			Execute:          comparison(func(l, r float64) bool { return l >= r }),
+			Latex:            "@l\\geq @r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     true,
+			Unitless:         true,
+		},
+		"==": {
+			Execute:          comparison(func(l, r float64) bool { return l == r }),
+			Latex:            "@l=@r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     false,
+			Unitless:         true,
+		},
+		"!=": {
+			Execute:          comparison(func(l, r float64) bool { return l != r }),
+			Latex:            "@l\\neq @r",
+			ParenthesisLeft:  true,
+			ParenthesisRight: true,
+			OrderMatters:     false,
+			Unitless:         true,
+		},
+		"=": {
+			Execute: func(l, r float64) (float64, error) {
+				return math.NaN(), errors.New("equations can only be used in solve, like solve(2*x = 10, x)")
+			},
+			Latex:            "@l=@r",
+			ParenthesisLeft:  false,
+			ParenthesisRight: false,
+			OrderMatters:     true,
+		},
 		"-": {
 			Execute: func(l, r float64) (float64, error) {
 				return l - r, nil
 			},
+			ExecuteComplex: func(l, r complex128) (complex128, error) {
+				return l - r, nil
+			},
+			ExecuteValue:     elementwise(func(a, b float64) float64 { return a - b }),
+			ExecuteInterval:  intervalSub,
+			Steps:            elementwiseSteps("-"),
 			Latex:            "@l-@r",
 			ParenthesisLeft:  true,
 			ParenthesisRight: true,
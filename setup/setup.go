@@ -2,18 +2,38 @@ package setup
 
 import "github.com/eliiasg/mdcalc/syntax"
 
+// The environment with every built-in operator and function, rendered in Danish
 func GenerateEnvironment(lib syntax.UnitLibrary) *syntax.Environment {
-	return &syntax.Environment{
+	return NewEnvironment(lib, Danish)
+}
+
+// An environment with the built-in operators and functions, where the words in the output are from locale.
+// The settings start as the default settings, and the formatter always uses the current settings of the environment
+func NewEnvironment(lib syntax.UnitLibrary, locale Locale) *syntax.Environment {
+	env := &syntax.Environment{
 		Operators:      genOperators(),
-		Functions:      genFunctions(),
+		Functions:      genFunctions(locale),
 		VariableValues: map[string]syntax.VariableValue{},
 		OperatorPowers: map[string]int{
 			"*":  1,
 			"/":  1,
 			"//": 1,
 			"^":  2,
+			// 2*9.81±0.02 is 2*(9.81±0.02)
+			"±": 3,
+			// an equation should always be split at the =
+			"=": -1,
+			// comparisons are split before + and -, so x+1 < 5 is (x+1) < 5
+			"<":  -1,
+			"<=": -1,
+			">":  -1,
+			">=": -1,
+			"==": -1,
+			"!=": -1,
 		},
-		Formatter:   &formatter{},
-		UnitLibrary: lib,
+		UnitLibrary: angleUnits{compoundUnits{lib}},
+		Settings:    syntax.DefaultSettings(),
 	}
+	env.Formatter = NewFormatter(&env.Settings, locale)
+	return env
 }
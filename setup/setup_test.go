@@ -0,0 +1,42 @@
+package setup
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+	"github.com/eliiasg/mdcalc/unitlib"
+)
+
+// calculates code in a new environment, settings are written like in an S line, like "intervals on"
+func calculate(t *testing.T, code string, settings ...string) (syntax.Value, error) {
+	t.Helper()
+	env := NewEnvironment(&unitlib.SimpleUnitLibrary{}, English)
+	for _, setting := range settings {
+		name, value, _ := strings.Cut(setting, " ")
+		if err := env.Settings.Set(name, value); err != nil {
+			t.Fatalf("invalid setting %q: %v", setting, err)
+		}
+	}
+	res, err := env.Calculate(code)
+	return res.Value, err
+}
+
+// checks err against the expected error, where "" means no error, and returns whether there was an error
+func checkError(t *testing.T, code string, err error, want string) bool {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%v: unexpected error: %v", code, err)
+	case want != "" && err == nil:
+		t.Errorf("%v: expected an error containing %q", code, want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Errorf("%v: expected an error containing %q, got %q", code, want, err)
+	}
+	return err != nil
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*math.Max(1, math.Abs(b))
+}
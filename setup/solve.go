@@ -0,0 +1,353 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/eliiasg/mdcalc/syntax"
+	"github.com/eliiasg/mdcalc/util"
+)
+
+const (
+	solveIterations = 200
+	// how many times the search around the guess is doubled before giving up
+	bracketSteps = 60
+)
+
+// solve(lhs = rhs, x, guess), the solution is saved in x
+type solver struct{}
+
+func (s solver) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	eq, name, err := s.equation(params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	guess := 1.0
+	if len(params) > 2 {
+		guess, err = numberParam(e, params[2])
+		if err != nil {
+			return math.NaN(), err
+		}
+	}
+	f := func(x float64) (float64, error) {
+		l, err := e.EvaluateWith(eq.Left, name, x)
+		if err != nil {
+			return math.NaN(), err
+		}
+		r, err := e.EvaluateWith(eq.Right, name, x)
+		if err != nil {
+			return math.NaN(), err
+		}
+		ln, err := syntax.Number(l)
+		if err != nil {
+			return math.NaN(), err
+		}
+		rn, err := syntax.Number(r)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return ln - rn, nil
+	}
+	// errors like undefined variables should be shown instead of just not finding a solution
+	if _, err := f(guess); err != nil {
+		return math.NaN(), err
+	}
+	res, err := findRoot(f, guess)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if err := e.SetVariable(name, syntax.VariableValue{Value: res, Unit: s.Unit(e, params)}); err != nil {
+		return math.NaN(), err
+	}
+	return res, nil
+}
+
+func (s solver) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	eq, name, err := s.equation(params)
+	if err != nil {
+		return "", err
+	}
+	res, err := e.FormatSymbolic(eq, name)
+	if err != nil {
+		return "", err
+	}
+	return res + "\\quad\\Leftrightarrow\\quad " + e.Formatter.FormatVar(name), nil
+}
+
+// the variable isolated, if it is only used once in the equation
+func (s solver) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	eq, name, err := s.equation(params)
+	if err != nil {
+		return nil, err
+	}
+	isolated := isolate(eq, name)
+	if isolated == nil {
+		return nil, nil
+	}
+	// x = 5 is already solved
+	if _, ok := isolated.(*syntax.ASTLiteral); ok {
+		return nil, nil
+	}
+	res, err := e.MakeLatexExpression(isolated)
+	if err != nil {
+		return nil, err
+	}
+	return []string{res}, nil
+}
+
+// the unit of the isolated variable, otherwise the unit of the guess
+func (s solver) Unit(e *syntax.Environment, params []syntax.ASTNode) string {
+	eq, name, err := s.equation(params)
+	if err != nil {
+		return ""
+	}
+	if isolated := isolate(eq, name); isolated != nil {
+		return e.GetUnit(isolated)
+	}
+	if len(params) > 2 {
+		return e.GetUnit(params[2])
+	}
+	return ""
+}
+
+func (s solver) equation(params []syntax.ASTNode) (*syntax.ASTOperator, string, error) {
+	eq, ok := params[0].(*syntax.ASTOperator)
+	if !ok || eq.Operator != "=" {
+		return nil, "", errors.New("first parameter must be an equation, like 2*x = 10")
+	}
+	name, ok := params[1].(*syntax.ASTLiteral)
+	if !ok || util.StrIsNum(name.Value) {
+		return nil, "", errors.New("second parameter must be the name of the variable to solve for")
+	}
+	return eq, name.Value, nil
+}
+
+func numberParam(e *syntax.Environment, node syntax.ASTNode) (float64, error) {
+	val, err := e.Evaluate(node)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return syntax.Number(val)
+}
+
+// Newton's method inside a bracket around the guess, falling back to bisection when a step leaves the bracket
+func findRoot(f func(float64) (float64, error), guess float64) (float64, error) {
+	lo, hi, ok := bracket(f, guess)
+	if !ok {
+		// roots that only touch 0, like for x^2 = 0, can not be bracketed
+		if x, ok := newton(f, guess); ok {
+			return x, nil
+		}
+		return math.NaN(), fmt.Errorf("no solution found near %v", guess)
+	}
+	if lo == hi {
+		return lo, nil
+	}
+	flo, _ := f(lo)
+	x := guess
+	if !(x > lo && x < hi) {
+		x = (lo + hi) / 2
+	}
+	for i := 0; i < solveIterations; i++ {
+		fx, err := f(x)
+		if err != nil || math.IsNaN(fx) {
+			fx = math.NaN()
+		}
+		if fx == 0 || hi-lo <= 1e-14*math.Max(1, math.Abs(x)) {
+			return x, nil
+		}
+		if !math.IsNaN(fx) {
+			if (fx < 0) == (flo < 0) {
+				lo, flo = x, fx
+			} else {
+				hi = x
+			}
+		}
+		next := x - fx/derivative(f, x)
+		if !(next > lo && next < hi) {
+			next = (lo + hi) / 2
+		}
+		if math.Abs(next-x) <= 1e-15*math.Max(1, math.Abs(x)) {
+			return next, nil
+		}
+		x = next
+	}
+	return x, nil
+}
+
+// searches further and further away from the guess for a sign change
+func bracket(f func(float64) (float64, error), guess float64) (float64, float64, bool) {
+	value := func(x float64) float64 {
+		res, err := f(x)
+		if err != nil {
+			return math.NaN()
+		}
+		return res
+	}
+	start := value(guess)
+	if start == 0 {
+		return guess, guess, true
+	}
+	step := math.Max(math.Abs(guess), 1) * 0.01
+	left, right := guess, guess
+	fLeft, fRight := start, start
+	for i := 0; i < bracketSteps; i++ {
+		for _, dir := range []float64{1, -1} {
+			x := guess + dir*step
+			fx := value(x)
+			if math.IsNaN(fx) || math.IsInf(fx, 0) {
+				continue
+			}
+			if dir > 0 {
+				if !math.IsNaN(fRight) && (fx < 0) != (fRight < 0) {
+					return right, x, true
+				}
+				right, fRight = x, fx
+			} else {
+				if !math.IsNaN(fLeft) && (fx < 0) != (fLeft < 0) {
+					return x, left, true
+				}
+				left, fLeft = x, fx
+			}
+		}
+		step *= 2
+	}
+	return 0, 0, false
+}
+
+func newton(f func(float64) (float64, error), x float64) (float64, bool) {
+	for i := 0; i < solveIterations; i++ {
+		fx, err := f(x)
+		if err != nil || math.IsNaN(fx) {
+			return math.NaN(), false
+		}
+		if math.Abs(fx) < 1e-12 {
+			return x, true
+		}
+		x -= fx / derivative(f, x)
+	}
+	return math.NaN(), false
+}
+
+func derivative(f func(float64) (float64, error), x float64) float64 {
+	h := 1e-7 * math.Max(1, math.Abs(x))
+	a, err := f(x + h)
+	if err != nil {
+		return math.NaN()
+	}
+	b, err := f(x - h)
+	if err != nil {
+		return math.NaN()
+	}
+	return (a - b) / (2 * h)
+}
+
+// Moves everything except the variable to the right side, returns nil if the variable is used more than once
+// or in a way that can not be undone
+func isolate(eq *syntax.ASTOperator, name string) syntax.ASTNode {
+	side, other := eq.Left, eq.Right
+	if uses(other, name) > 0 {
+		side, other = other, side
+	}
+	if uses(side, name) != 1 || uses(other, name) != 0 {
+		return nil
+	}
+	for {
+		switch node := side.(type) {
+		case *syntax.ASTLiteral:
+			return other
+		case *syntax.ASTUnitOverride:
+			side = node.Child
+		case *syntax.ASTPercent:
+			side, other = node.Child, op("*", other, number(100))
+		case *syntax.ASTFunction:
+			if node.Name == "sqrt" && len(node.Params) == 1 {
+				side, other = node.Params[0], op("^", other, number(2))
+			} else if node.Name == "par" && len(node.Params) == 1 {
+				side = node.Params[0]
+			} else {
+				return nil
+			}
+		case *syntax.ASTOperator:
+			node = percentProduct(node)
+			inLeft := uses(node.Left, name) == 1
+			var ok bool
+			side, other, ok = undo(node, inLeft, other)
+			if !ok {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// undoes the operator on both sides of the equation, returns the rest of the side with the variable
+func undo(node *syntax.ASTOperator, inLeft bool, other syntax.ASTNode) (syntax.ASTNode, syntax.ASTNode, bool) {
+	l, r := node.Left, node.Right
+	if inLeft {
+		switch node.Operator {
+		case "+":
+			return l, op("-", other, r), true
+		case "-":
+			return l, op("+", other, r), true
+		case "*":
+			return l, op("/", other, r), true
+		case "/", "%":
+			return l, op("*", other, r), true
+		case "^":
+			if lit, ok := r.(*syntax.ASTLiteral); ok && lit.Value == "2" {
+				return l, fun("sqrt", other), true
+			}
+			return l, fun("root", r, other), true
+		}
+		return nil, nil, false
+	}
+	switch node.Operator {
+	case "+":
+		return r, op("-", other, l), true
+	case "-":
+		return r, op("-", l, other), true
+	case "*":
+		return r, op("/", other, l), true
+	case "/", "%":
+		return r, op("/", l, other), true
+	case "^":
+		return r, fun("log", l, other), true
+	}
+	return nil, nil, false
+}
+
+// how many times the variable is used
+func uses(node syntax.ASTNode, name string) int {
+	switch n := node.(type) {
+	case *syntax.ASTLiteral:
+		if n.Value == name {
+			return 1
+		}
+	case *syntax.ASTUnitOverride:
+		return uses(n.Child, name)
+	case *syntax.ASTPercent:
+		return uses(n.Child, name)
+	case *syntax.ASTComment:
+		return uses(n.Child, name)
+	case *syntax.ASTOperator:
+		return uses(n.Left, name) + uses(n.Right, name)
+	case *syntax.ASTFunction:
+		res := 0
+		for _, p := range n.Params {
+			res += uses(p, name)
+		}
+		return res
+	case *syntax.ASTList:
+		res := 0
+		for _, item := range n.Items {
+			res += uses(item, name)
+		}
+		return res
+	case *syntax.ASTMatrix:
+		return uses(&syntax.ASTList{Items: n.Items()}, name)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package setup
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		code string
+		want float64
+		// defaults to 1e-9
+		tolerance float64
+		err       string
+	}{
+		{code: "solve(2*x = 10, x)", want: 5},
+		{code: "solve(x = 3 - x, x)", want: 1.5},
+		{code: "solve(2*(x+1) = 10, x)", want: 4},
+		{code: "solve(x^2 = 2, x)", want: math.Sqrt2},
+		{code: "solve(x^2 = 2, x, -1)", want: -math.Sqrt2},
+		{code: "solve(x^3 - x = 1, x)", want: 1.324717957244746},
+		{code: "solve(1000*1.05^n = 2000, n, 10)", want: math.Log(2) / math.Log(1.05)},
+		// roots that only touch 0 are found with Newton's method, which is less precise
+		{code: "solve(x^2 = 0, x)", want: 0, tolerance: 1e-5},
+		{code: "solve(x^2 = -1, x)", err: "no solution found"},
+		{code: "solve(2*x, x)", err: "first parameter must be an equation"},
+		{code: "solve(2*x = 10, 3)", err: "second parameter must be the name"},
+		{code: "solve(2*x = y, x)", err: "variable 'y' undefined"},
+	}
+	for _, test := range tests {
+		res, err := calculate(t, test.code)
+		if checkError(t, test.code, err, test.err) || test.err != "" {
+			continue
+		}
+		tolerance := test.tolerance
+		if tolerance == 0 {
+			tolerance = 1e-9
+		}
+		if n, _ := syntax.Number(res); !near(n, test.want, tolerance) {
+			t.Errorf("%v: expected %v, got %v", test.code, test.want, res)
+		}
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     func(float64) float64
+		guess float64
+		want  float64
+		fails bool
+	}{
+		{name: "line", f: func(x float64) float64 { return 3*x - 6 }, guess: 1, want: 2},
+		{name: "far from the guess", f: func(x float64) float64 { return x - 1e6 }, guess: 1, want: 1e6},
+		{name: "cosine", f: func(x float64) float64 { return math.Cos(x) - x }, guess: 1, want: 0.7390851332151607},
+		{name: "double root", f: func(x float64) float64 { return (x - 3) * (x - 3) }, guess: 1, want: 3},
+		{name: "undefined far from the root", f: math.Log, guess: 2, want: 1},
+		{name: "no root", f: func(x float64) float64 { return x*x + 1 }, guess: 1, fails: true},
+	}
+	for _, test := range tests {
+		res, err := findRoot(func(x float64) (float64, error) { return test.f(x), nil }, test.guess)
+		if test.fails {
+			if err == nil {
+				t.Errorf("%v: expected no solution, got %v", test.name, res)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		} else if !near(res, test.want, 1e-6) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.want, res)
+		}
+	}
+}
+
+func TestFindRootError(t *testing.T) {
+	if _, err := findRoot(func(x float64) (float64, error) { return 0, errors.New("broken") }, 1); err == nil {
+		t.Error("expected an error when f always fails")
+	}
+}
@@ -0,0 +1,173 @@
+package setup
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// more iterations than this is most likely a mistake
+const maxIterations = 1000000
+
+// sum(i, 1, n, expr) and prod(i, 1, n, expr)
+type series struct {
+	product bool
+}
+
+func (s series) Evaluate(e *syntax.Environment, params []syntax.ASTNode) (syntax.Value, error) {
+	name, from, to, err := s.bounds(e, params)
+	if err != nil {
+		return math.NaN(), err
+	}
+	res := s.identity()
+	for i := from; i <= to; i++ {
+		val, err := e.EvaluateWith(params[3], name, float64(i))
+		if err != nil {
+			return math.NaN(), err
+		}
+		n, err := syntax.Number(val)
+		if err != nil {
+			return math.NaN(), err
+		}
+		res = s.combine(res, n)
+	}
+	return res, nil
+}
+
+func (s series) Format(e *syntax.Environment, params []syntax.ASTNode) (string, error) {
+	name, ok := params[0].(*syntax.ASTLiteral)
+	if !ok {
+		return "", errors.New("first parameter must be the name of a variable")
+	}
+	from, err := e.MakeLatexExpression(params[1])
+	if err != nil {
+		return "", err
+	}
+	to, err := e.MakeLatexExpression(params[2])
+	if err != nil {
+		return "", err
+	}
+	expr, err := e.FormatSymbolic(params[3], name.Value)
+	if err != nil {
+		return "", err
+	}
+	// so it is clear where the sum ends, like in sum(i, 1, 3, 2*i)*2
+	if op, ok := params[3].(*syntax.ASTOperator); ok && op.Operator != "^" && op.Operator != "/" {
+		expr = e.Formatter.FormatParenthesie(expr)
+	}
+	return fmt.Sprintf("%v_{%v=%v}^{%v} %v", s.symbol(), e.Formatter.FormatVar(name.Value), from, to, expr), nil
+}
+
+// writes out every term, first as the expression and then as the value of the term
+func (s series) Steps(e *syntax.Environment, params []syntax.ASTNode) ([]string, error) {
+	name, from, to, err := s.bounds(e, params)
+	if err != nil {
+		return nil, err
+	}
+	if to < from || to-from+1 > e.Settings.ExpandTerms {
+		return nil, nil
+	}
+	terms := make([]string, 0)
+	values := make([]string, 0)
+	for i := from; i <= to; i++ {
+		err := e.With(name, float64(i), func() error {
+			term, err := e.MakeLatexExpression(params[3])
+			if err != nil {
+				return err
+			}
+			if s.needParenthesis(e, params[3]) {
+				term = e.Formatter.FormatParenthesie(term)
+			}
+			terms = append(terms, term)
+			val, err := e.Evaluate(params[3])
+			if err != nil {
+				return err
+			}
+			values = append(values, e.Formatter.FormatNumber(val, -1, "", ""))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sep := "+"
+	if s.product {
+		sep = "\\cdot"
+	}
+	res := []string{strings.Join(terms, sep)}
+	// no reason to write the same terms twice, like for sum(i, 1, 3, i)
+	if _, literal := params[3].(*syntax.ASTLiteral); !literal {
+		res = append(res, strings.Join(values, sep))
+	}
+	return res, nil
+}
+
+func (s series) bounds(e *syntax.Environment, params []syntax.ASTNode) (string, int, int, error) {
+	name, ok := params[0].(*syntax.ASTLiteral)
+	if !ok {
+		return "", 0, 0, errors.New("first parameter must be the name of a variable")
+	}
+	from, err := integerParam(e, params[1])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	to, err := integerParam(e, params[2])
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if to-from >= maxIterations {
+		return "", 0, 0, fmt.Errorf("too many terms, %v", to-from+1)
+	}
+	return name.Value, from, to, nil
+}
+
+func (s series) identity() float64 {
+	if s.product {
+		return 1
+	}
+	return 0
+}
+
+func (s series) combine(res, n float64) float64 {
+	if s.product {
+		return res * n
+	}
+	return res + n
+}
+
+func (s series) symbol() string {
+	if s.product {
+		return "\\prod"
+	}
+	return "\\sum"
+}
+
+// sums only need parenthesis around terms for readability, products need them around + and -
+func (s series) needParenthesis(e *syntax.Environment, expr syntax.ASTNode) bool {
+	op, ok := expr.(*syntax.ASTOperator)
+	if !ok {
+		return false
+	}
+	if !s.product {
+		return op.Operator == "+" || op.Operator == "-"
+	}
+	return e.OperatorPowers[op.Operator] < e.OperatorPowers["*"]
+}
+
+func integerParam(e *syntax.Environment, node syntax.ASTNode) (int, error) {
+	val, err := e.Evaluate(node)
+	if err != nil {
+		return 0, err
+	}
+	n, err := syntax.Number(val)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+		return 0, fmt.Errorf("expected a whole number, got %v", n)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,177 @@
+package setup
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// steps are left out for larger lists, since writing out every observation is not useful
+const maxStepTerms = 12
+
+// Wraps a function of a list of observations as a syntax function
+func listFunction(fun func(syntax.List) (syntax.Value, error)) func([]syntax.Value) (syntax.Value, error) {
+	return func(args []syntax.Value) (syntax.Value, error) {
+		list, ok := args[0].(syntax.List)
+		if !ok {
+			return math.NaN(), errors.New("expected a list, like [12, 15, 9]")
+		}
+		if len(list) == 0 {
+			return math.NaN(), errors.New("list is empty")
+		}
+		return fun(list)
+	}
+}
+
+func listSum(list syntax.List) (syntax.Value, error) {
+	res := 0.0
+	for _, n := range list {
+		res += n
+	}
+	return res, nil
+}
+
+func listMean(list syntax.List) (syntax.Value, error) {
+	sum, _ := listSum(list)
+	return sum.(float64) / float64(len(list)), nil
+}
+
+func listMedian(list syntax.List) (syntax.Value, error) {
+	return median(sorted(list)), nil
+}
+
+// sample variance, dividing by n-1
+func listVariance(list syntax.List) (syntax.Value, error) {
+	if len(list) < 2 {
+		return math.NaN(), errors.New("variance needs at least 2 observations")
+	}
+	mean, _ := listMean(list)
+	res := 0.0
+	for _, n := range list {
+		res += (n - mean.(float64)) * (n - mean.(float64))
+	}
+	return res / float64(len(list)-1), nil
+}
+
+func listStdev(list syntax.List) (syntax.Value, error) {
+	v, err := listVariance(list)
+	if err != nil {
+		return math.NaN(), err
+	}
+	return math.Sqrt(v.(float64)), nil
+}
+
+// first quartile, median and third quartile, the quartiles are the medians of each half, excluding the median for odd lengths
+func listQuartiles(list syntax.List) (syntax.Value, error) {
+	s := sorted(list)
+	half := len(s) / 2
+	if len(s) < 2 {
+		return syntax.List{s[0], s[0], s[0]}, nil
+	}
+	return syntax.List{median(s[:half]), median(s), median(s[len(s)-half:])}, nil
+}
+
+func listMin(list syntax.List) (syntax.Value, error) {
+	return sorted(list)[0], nil
+}
+
+func listMax(list syntax.List) (syntax.Value, error) {
+	return sorted(list)[len(list)-1], nil
+}
+
+func listCount(list syntax.List) (syntax.Value, error) {
+	return float64(len(list)), nil
+}
+
+func sorted(list syntax.List) syntax.List {
+	res := make(syntax.List, len(list))
+	copy(res, list)
+	sort.Float64s(res)
+	return res
+}
+
+// list must be sorted
+func median(list syntax.List) float64 {
+	if len(list)%2 == 1 {
+		return list[len(list)/2]
+	}
+	return (list[len(list)/2-1] + list[len(list)/2]) / 2
+}
+
+// returns the list if the steps should be shown
+func stepList(args []syntax.Value) (syntax.List, bool) {
+	list, ok := args[0].(syntax.List)
+	return list, ok && len(list) > 0 && len(list) <= maxStepTerms
+}
+
+func joinNumbers(f syntax.Formatter, list syntax.List, sep string) string {
+	items := make([]string, len(list))
+	for i, n := range list {
+		items[i] = f.FormatNumber(n, -1, "", "")
+	}
+	return strings.Join(items, sep)
+}
+
+func sumSteps(f syntax.Formatter, args []syntax.Value) []string {
+	list, ok := stepList(args)
+	if !ok || len(list) < 2 {
+		return nil
+	}
+	return []string{joinNumbers(f, list, "+")}
+}
+
+func meanSteps(f syntax.Formatter, args []syntax.Value) []string {
+	list, ok := stepList(args)
+	if !ok {
+		return nil
+	}
+	sum, _ := listSum(list)
+	n := f.FormatNumber(float64(len(list)), -1, "", "")
+	return []string{
+		"\\dfrac{" + joinNumbers(f, list, "+") + "}{" + n + "}",
+		"\\dfrac{" + f.FormatNumber(sum, -1, "", "") + "}{" + n + "}",
+	}
+}
+
+func medianSteps(f syntax.Formatter, args []syntax.Value) []string {
+	list, ok := stepList(args)
+	if !ok {
+		return nil
+	}
+	s := sorted(list)
+	res := []string{"\\text{median}" + f.FormatNumber(s, -1, "", "")}
+	if len(s)%2 == 0 {
+		res = append(res, "\\dfrac{"+joinNumbers(f, s[len(s)/2-1:len(s)/2+1], "+")+"}{"+f.FormatNumber(2.0, -1, "", "")+"}")
+	}
+	return res
+}
+
+// the sum of squared deviations divided by n-1
+func varianceFraction(f syntax.Formatter, list syntax.List) string {
+	mean, _ := listMean(list)
+	terms := make([]string, len(list))
+	for i, n := range list {
+		terms[i] = "(" + f.FormatNumber(n, -1, "", "") + "-" + f.FormatNumber(mean, 2, "", "") + ")^{2}"
+	}
+	return "\\dfrac{" + strings.Join(terms, "+") + "}{" + f.FormatNumber(float64(len(list)-1), -1, "", "") + "}"
+}
+
+func varianceSteps(f syntax.Formatter, args []syntax.Value) []string {
+	list, ok := stepList(args)
+	if !ok || len(list) < 2 {
+		return nil
+	}
+	return []string{varianceFraction(f, list)}
+}
+
+func stdevSteps(f syntax.Formatter, args []syntax.Value) []string {
+	list, ok := stepList(args)
+	if !ok || len(list) < 2 {
+		return nil
+	}
+	v, _ := listVariance(list)
+	return []string{"\\sqrt{" + varianceFraction(f, list) + "}", "\\sqrt{" + f.FormatNumber(v, 2, "", "") + "}"}
+}
@@ -0,0 +1,106 @@
+package setup
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/eliiasg/mdcalc/syntax"
+)
+
+// units written in brackets, like [kr/h], are calculated and written without the unit library
+type compoundUnits struct {
+	syntax.UnitLibrary
+}
+
+func (l compoundUnits) GetUnitDisplayName(unit string) string {
+	if !syntax.IsCompoundUnit(unit) {
+		return l.UnitLibrary.GetUnitDisplayName(unit)
+	}
+	parsed, err := syntax.ParseUnit(unit)
+	if err != nil {
+		return unit
+	}
+	return parsed.Latex()
+}
+
+func (l compoundUnits) GetOperatorResult(left, right, operator string, orderMatters bool) string {
+	if operator == "^" {
+		return l.power(left, right)
+	}
+	if !syntax.IsCompoundUnit(left) && !syntax.IsCompoundUnit(right) {
+		return l.UnitLibrary.GetOperatorResult(left, right, operator, orderMatters)
+	}
+	if operator == "root" {
+		degree, ok := wholeNumber(right)
+		if !ok {
+			return l.UnitLibrary.GetOperatorResult(left, right, operator, orderMatters)
+		}
+		if res, ok := l.root(left, degree); ok {
+			return res
+		}
+		return l.UnitLibrary.GetOperatorResult(left, right, operator, orderMatters)
+	}
+	a, errA := syntax.ParseUnit(left)
+	b, errB := syntax.ParseUnit(right)
+	if errA != nil || errB != nil {
+		return l.UnitLibrary.GetOperatorResult(left, right, operator, orderMatters)
+	}
+	switch operator {
+	case "*":
+		return a.Mul(b).String()
+	case "/", "//":
+		return a.Mul(b.Pow(-1)).String()
+	}
+	// the sides of + and - must have the same unit
+	if left == "" || left == right {
+		return right
+	}
+	if right == "" {
+		return left
+	}
+	return l.UnitLibrary.GetOperatorResult(left, right, operator, orderMatters)
+}
+
+// whole powers of units without brackets are compound units, so (3kg)^2 is in [kg^2]
+func (l compoundUnits) power(unit, exp string) string {
+	parsed, err := syntax.ParseUnit(unit)
+	if err != nil {
+		return l.UnitLibrary.GetOperatorResult(unit, exp, "^", true)
+	}
+	if n, ok := wholeNumber(exp); ok {
+		if n == 1 {
+			return unit
+		}
+		return parsed.Pow(n).String()
+	}
+	// [m^2]^0.5 is a square root
+	if n, err := strconv.ParseFloat(exp, 64); err == nil && n > 0 {
+		if degree, ok := wholeNumber(strconv.FormatFloat(1/n, 'f', -1, 64)); ok {
+			if res, ok := l.root(unit, degree); ok {
+				return res
+			}
+		}
+	}
+	// like kg^1.5, which can not be written with whole exponents
+	return l.UnitLibrary.GetOperatorResult(unit, exp, "^", true)
+}
+
+func (l compoundUnits) root(unit string, degree int) (string, bool) {
+	parsed, err := syntax.ParseUnit(unit)
+	if err != nil {
+		return "", false
+	}
+	res, ok := parsed.Root(degree)
+	if !ok {
+		return "", false
+	}
+	return res.String(), true
+}
+
+func wholeNumber(s string) (int, bool) {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n != math.Trunc(n) || math.Abs(n) > 1000 {
+		return 0, false
+	}
+	return int(n), true
+}
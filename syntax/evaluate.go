@@ -4,17 +4,37 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/eliiasg/mdcalc/util"
 )
 
-func (e *Environment) Evaluate(root ASTNode) (float64, error) {
+func (e *Environment) Evaluate(root ASTNode) (Value, error) {
 	switch node := root.(type) {
 	case *ASTComment:
 		return e.Evaluate(node.Child)
 	case *ASTUnitOverride:
-		return e.Evaluate(node.Child)
+		res, err := e.Evaluate(node.Child)
+		if err != nil || !e.imaginary(node.Unit) {
+			return res, err
+		}
+		c, err := Complex(res)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return ComplexValue(c * 1i), nil
+	case *ASTPercent:
+		res, err := e.Evaluate(node.Child)
+		if err != nil {
+			return math.NaN(), err
+		}
+		n, err := Number(res)
+		if err != nil {
+			return math.NaN(), fmt.Errorf("error on '%%': %v", err.Error())
+		}
+		return n / 100, nil
 	case *ASTVarSetter:
 		if util.StrIsNum(node.VarName) {
 			return math.NaN(), fmt.Errorf("cannot assign to number '%v'", node.VarName)
@@ -23,8 +43,12 @@ func (e *Environment) Evaluate(root ASTNode) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		e.VariableValues[node.VarName] = VariableValue{Value: res, Unit: e.GetUnit(node.Child)}
+		if err := e.SetVariable(node.VarName, VariableValue{Value: res, Unit: e.GetUnit(node.Child)}); err != nil {
+			return math.NaN(), err
+		}
 		return res, nil
+	case *ASTFuncSetter:
+		return math.NaN(), fmt.Errorf("function '%v' can only be defined at the start of a calculation", node.Name)
 	case *ASTLiteral:
 		res, _, err := e.parseLiteral(node)
 		if err != nil {
@@ -44,7 +68,38 @@ func (e *Environment) Evaluate(root ASTNode) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		res, err := op.Execute(resL, resR)
+		if isInterval(resL) || isInterval(resR) {
+			return e.intervalOperator(node, op, resL, resR)
+		}
+		if op.Execute != nil && (isUncertain(resL) || isUncertain(resR)) {
+			res, err := Propagate(operatorFunc(node, op), []Value{resL, resR})
+			if err != nil {
+				return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+			}
+			return res, nil
+		}
+		if op.ExecuteValue != nil && (op.Execute == nil || !isNumber(resL) || !isNumber(resR)) {
+			res, err := op.ExecuteValue(resL, resR)
+			if err != nil {
+				return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+			}
+			return res, nil
+		}
+		if isComplex(resL) || isComplex(resR) {
+			return e.complexOperator(node, op, resL, resR)
+		}
+		l, err := Number(resL)
+		if err != nil {
+			return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+		}
+		r, err := Number(resR)
+		if err != nil {
+			return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+		}
+		if PercentChange(node) {
+			r *= l
+		}
+		res, err := op.Execute(l, r)
 		if err != nil {
 			return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
 		}
@@ -54,7 +109,17 @@ func (e *Environment) Evaluate(root ASTNode) (float64, error) {
 		if err != nil {
 			return math.NaN(), err
 		}
-		evalRes := make([]float64, len(node.Params))
+		if fun.Special != nil {
+			res, err := fun.Special.Evaluate(e, node.Params)
+			if err != nil {
+				return math.NaN(), fmt.Errorf("error in function '%v': %v", node.Name, err.Error())
+			}
+			return res, nil
+		}
+		if err := e.checkUnits(fun.Unit, node); err != nil {
+			return math.NaN(), fmt.Errorf("error in function '%v': %v", node.Name, err.Error())
+		}
+		evalRes := make([]Value, len(node.Params))
 		for i, param := range node.Params {
 			res, err := e.Evaluate(param)
 			if err != nil {
@@ -62,15 +127,286 @@ func (e *Environment) Evaluate(root ASTNode) (float64, error) {
 			}
 			evalRes[i] = res
 		}
-		res, err := fun.Execute(evalRes)
+		res, err := fun.call(evalRes, e.Settings.Imaginary != "")
+		// errors from user defined functions are just errors in their body
+		if err != nil && fun.Body != nil {
+			return math.NaN(), err
+		}
 		if err != nil {
 			return math.NaN(), fmt.Errorf("error in function '%v': %v", node.Name, err.Error())
 		}
 		return res, nil
+	case *ASTList:
+		if e.Settings.Intervals && len(node.Items) == 2 {
+			return e.evaluateInterval(node)
+		}
+		res := make(List, len(node.Items))
+		for i, item := range node.Items {
+			val, err := e.Evaluate(item)
+			if err != nil {
+				return math.NaN(), err
+			}
+			res[i], err = Number(val)
+			if err != nil {
+				return math.NaN(), fmt.Errorf("error in list: %v", err.Error())
+			}
+		}
+		return res, nil
+	case *ASTMatrix:
+		res := make(Matrix, len(node.Rows))
+		for i, row := range node.Rows {
+			res[i] = make([]float64, len(row))
+			for j, item := range row {
+				val, err := e.Evaluate(item)
+				if err != nil {
+					return math.NaN(), err
+				}
+				res[i][j], err = Number(val)
+				if err != nil {
+					return math.NaN(), fmt.Errorf("error in matrix: %v", err.Error())
+				}
+			}
+		}
+		return res, nil
 	}
 	return math.NaN(), errors.New("invalid ast node")
 }
 
+func (e *Environment) evaluateInterval(node *ASTList) (Value, error) {
+	bounds := [2]float64{}
+	for i, item := range node.Items {
+		val, err := e.Evaluate(item)
+		if err != nil {
+			return math.NaN(), err
+		}
+		bounds[i], err = Number(val)
+		if err != nil {
+			return math.NaN(), fmt.Errorf("error in interval: %v", err.Error())
+		}
+	}
+	if bounds[0] > bounds[1] {
+		return math.NaN(), errors.New("the lower bound of an interval must be at most the upper bound")
+	}
+	return Interval{Lo: bounds[0], Hi: bounds[1]}, nil
+}
+
+func (e *Environment) complexOperator(node *ASTOperator, op Operator, resL, resR Value) (Value, error) {
+	if op.ExecuteComplex == nil {
+		return math.NaN(), fmt.Errorf("operator '%v' does not support complex numbers", node.Operator)
+	}
+	l, err := Complex(resL)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	r, err := Complex(resR)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	if PercentChange(node) {
+		r *= l
+	}
+	res, err := op.ExecuteComplex(l, r)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	return ComplexValue(res), nil
+}
+
+func (e *Environment) intervalOperator(node *ASTOperator, op Operator, resL, resR Value) (Value, error) {
+	if op.ExecuteInterval == nil {
+		return math.NaN(), fmt.Errorf("operator '%v' does not support intervals", node.Operator)
+	}
+	if PercentChange(node) {
+		return math.NaN(), errors.New("percentages of intervals are not supported")
+	}
+	l, err := Bounds(resL)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	r, err := Bounds(resR)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	res, err := op.ExecuteInterval(l, r)
+	if err != nil {
+		return math.NaN(), fmt.Errorf("error on operator '%v': %v", node.Operator, err.Error())
+	}
+	return res, nil
+}
+
+func (f Function) call(args []Value, complexMode bool) (Value, error) {
+	if slices.ContainsFunc(args, isInterval) {
+		if f.ExecuteInterval == nil {
+			return math.NaN(), errors.New("intervals are not supported")
+		}
+		intervals := make([]Interval, len(args))
+		for i, arg := range args {
+			n, err := Bounds(arg)
+			if err != nil {
+				return math.NaN(), err
+			}
+			intervals[i] = n
+		}
+		return f.ExecuteInterval(intervals)
+	}
+	if f.ExecuteComplex != nil && (complexMode || f.ExecuteValue == nil && f.Execute == nil || slices.ContainsFunc(args, isComplex)) {
+		nums := make([]complex128, len(args))
+		for i, arg := range args {
+			n, err := Complex(arg)
+			if err != nil {
+				return math.NaN(), err
+			}
+			nums[i] = n
+		}
+		res, err := f.ExecuteComplex(nums)
+		if err != nil {
+			return math.NaN(), err
+		}
+		return ComplexValue(res), nil
+	}
+	if f.Execute != nil && slices.ContainsFunc(args, isUncertain) {
+		return Propagate(f.Execute, args)
+	}
+	if f.ExecuteValue != nil {
+		return f.ExecuteValue(args)
+	}
+	nums := make([]float64, len(args))
+	for i, arg := range args {
+		n, err := Number(arg)
+		if err != nil {
+			return math.NaN(), err
+		}
+		nums[i] = n
+	}
+	return f.Execute(nums)
+}
+
+const maxCallDepth = 1000
+
+// a+8% and a-8% add or subtract 8% of a
+func PercentChange(node *ASTOperator) bool {
+	_, ok := node.Right.(*ASTPercent)
+	return ok && (node.Operator == "+" || node.Operator == "-")
+}
+
+// Adds a user defined function, redefining user defined functions is allowed, but built-in functions can not be replaced
+func (e *Environment) DefineFunction(node *ASTFuncSetter) error {
+	if util.StrIsNum(node.Name) {
+		return fmt.Errorf("invalid function name '%v'", node.Name)
+	}
+	funs, ok := e.Functions[node.Name]
+	if !ok {
+		funs = make(map[int]Function)
+		e.Functions[node.Name] = funs
+	}
+	if old, ok := funs[len(node.Params)]; ok && old.Body == nil {
+		return fmt.Errorf("cannot redefine built-in function '%v'", node.Name)
+	}
+	params := make([]string, len(node.Params))
+	for i := range node.Params {
+		params[i] = "@" + fmt.Sprint(i)
+	}
+	body, err := e.expand(node.Child)
+	if err != nil {
+		return err
+	}
+	names := node.Params
+	funs[len(node.Params)] = Function{
+		ExecuteValue: func(args []Value) (Value, error) {
+			return e.callFunction(names, body, args)
+		},
+		Latex:  node.Name + "(" + strings.Join(params, ",") + ")",
+		Params: names,
+		Body:   body,
+	}
+	return nil
+}
+
+// replaces special functions that can be expanded with their expression
+func (e *Environment) expand(root ASTNode) (ASTNode, error) {
+	switch node := root.(type) {
+	case *ASTFunction:
+		fun, err := e.getFunction(node)
+		if err != nil {
+			return root, nil
+		}
+		if ex, ok := fun.Special.(SpecialExpand); ok {
+			res, err := ex.Expand(e, node.Params)
+			if err != nil {
+				return nil, fmt.Errorf("error in function '%v': %v", node.Name, err.Error())
+			}
+			if res != nil {
+				return res, nil
+			}
+		}
+		if fun.Special != nil {
+			return root, nil
+		}
+		params := make([]ASTNode, len(node.Params))
+		changed := false
+		for i, param := range node.Params {
+			params[i], err = e.expand(param)
+			if err != nil {
+				return nil, err
+			}
+			changed = changed || params[i] != param
+		}
+		if !changed {
+			return root, nil
+		}
+		return &ASTFunction{Name: node.Name, Params: params}, nil
+	case *ASTOperator:
+		l, err := e.expand(node.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := e.expand(node.Right)
+		if err != nil {
+			return nil, err
+		}
+		if l == node.Left && r == node.Right {
+			return root, nil
+		}
+		return &ASTOperator{Operator: node.Operator, Left: l, Right: r}, nil
+	case *ASTUnitOverride:
+		child, err := e.expand(node.Child)
+		if err != nil {
+			return nil, err
+		}
+		if child == node.Child {
+			return root, nil
+		}
+		return &ASTUnitOverride{Unit: node.Unit, Child: child}, nil
+	}
+	return root, nil
+}
+
+// evaluates body with params set to args, restoring any variables with the same names afterwards
+func (e *Environment) callFunction(params []string, body ASTNode, args []Value) (Value, error) {
+	if e.depth >= maxCallDepth {
+		return math.NaN(), errors.New("too many nested function calls, does a function call itself?")
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+	old := make(map[string]VariableValue)
+	for i, p := range params {
+		if v, ok := e.VariableValues[p]; ok {
+			old[p] = v
+		}
+		e.VariableValues[p] = VariableValue{Value: args[i]}
+	}
+	defer func() {
+		for _, p := range params {
+			if v, ok := old[p]; ok {
+				e.VariableValues[p] = v
+			} else {
+				delete(e.VariableValues, p)
+			}
+		}
+	}()
+	return e.Evaluate(body)
+}
+
 func (e *Environment) getFunction(node *ASTFunction) (Function, error) {
 	funs, ok := e.Functions[node.Name]
 	if !ok {
@@ -83,7 +419,7 @@ func (e *Environment) getFunction(node *ASTFunction) (Function, error) {
 	return fun, nil
 }
 
-func (e *Environment) parseLiteral(node *ASTLiteral) (float64, string, error) {
+func (e *Environment) parseLiteral(node *ASTLiteral) (Value, string, error) {
 	if util.StrIsNum(node.Value) {
 		r, err := strconv.ParseFloat(node.Value, 64)
 		if err != nil {
@@ -92,8 +428,15 @@ func (e *Environment) parseLiteral(node *ASTLiteral) (float64, string, error) {
 		return r, "", nil
 	}
 	val, ok := e.VariableValues[node.Value]
+	if !ok && e.imaginary(node.Value) {
+		return 1i, "", nil
+	}
 	if !ok {
 		return math.NaN(), "", fmt.Errorf("variable '%v' undefined", node.Value)
 	}
+	if e.read == nil {
+		e.read = make(map[string]bool)
+	}
+	e.read[node.Value] = true
 	return val.Value, val.Unit, nil
 }
@@ -3,8 +3,11 @@ package syntax
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+
+	"github.com/eliiasg/mdcalc/util"
 )
 
 func (e *Environment) GetUnit(root ASTNode) string {
@@ -13,6 +16,9 @@ func (e *Environment) GetUnit(root ASTNode) string {
 		if node.Unit == "None" {
 			return ""
 		}
+		if e.imaginary(node.Unit) {
+			return e.GetUnit(node.Child)
+		}
 		return node.Unit
 	case *ASTLiteral:
 		_, unit, err := e.parseLiteral(node)
@@ -22,11 +28,29 @@ func (e *Environment) GetUnit(root ASTNode) string {
 		return unit
 	case *ASTComment:
 		return e.GetUnit(node.Child)
+	case *ASTPercent:
+		return ""
 	case *ASTVarSetter:
 		return e.GetUnit(node.Child)
 	case *ASTOperator:
+		if op, ok := e.Operators[node.Operator]; ok && op.Unitless {
+			return ""
+		}
 		l := e.GetUnit(node.Left)
 		r := e.GetUnit(node.Right)
+		// the library is asked for the power of a unit, like [m]^2, with the exponent as the right side
+		if node.Operator == "^" && l != "" && r == "" {
+			if exp, err := e.Evaluate(node.Right); err == nil {
+				if n, err := Number(exp); err == nil {
+					return e.UnitLibrary.GetOperatorResult(l, strconv.FormatFloat(n, 'f', -1, 64), "^", true)
+				}
+			}
+			return l
+		}
+		// units like [kr/h] are calculated by the library, even when one side has no unit, so 1/[h] is [1/h]
+		if IsCompoundUnit(l) || IsCompoundUnit(r) {
+			return e.UnitLibrary.GetOperatorResult(l, r, node.Operator, true)
+		}
 		if l == "" || l == r {
 			return r
 		}
@@ -39,23 +63,128 @@ func (e *Environment) GetUnit(root ASTNode) string {
 		}
 		return e.UnitLibrary.GetOperatorResult(l, r, node.Operator, op.OrderMatters)
 	case *ASTFunction:
-		return ""
+		fun, err := e.getFunction(node)
+		if err != nil {
+			return ""
+		}
+		if u, ok := fun.Special.(SpecialUnit); ok {
+			return u.Unit(e, node.Params)
+		}
+		return e.functionUnit(fun.Unit, node)
+	case *ASTList:
+		// only keep the unit if every item has the same unit
+		unit := ""
+		for i, item := range node.Items {
+			u := e.GetUnit(item)
+			if i != 0 && u != unit {
+				return ""
+			}
+			unit = u
+		}
+		return unit
+	case *ASTMatrix:
+		return e.GetUnit(&ASTList{Items: node.Items()})
+	}
+	return ""
+}
+
+// the degree of root(n, x) when n is a whole number or a variable, it is not evaluated since that would do things like setting variables again
+func (e *Environment) literalDegree(node ASTNode) (int, bool) {
+	lit, ok := node.(*ASTLiteral)
+	if !ok {
+		return 0, false
+	}
+	var n float64
+	if util.StrIsNum(lit.Value) {
+		var err error
+		if n, err = strconv.ParseFloat(lit.Value, 64); err != nil {
+			return 0, false
+		}
+	} else if v, ok := e.VariableValues[lit.Value]; ok {
+		if n, ok = v.Value.(float64); !ok {
+			return 0, false
+		}
+	} else {
+		return 0, false
+	}
+	if n != math.Trunc(n) || n < 1 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func (e *Environment) functionUnit(rule UnitRule, node *ASTFunction) string {
+	switch rule.Kind {
+	case SameUnit:
+		return e.GetUnit(node.Params[rule.Param])
+	case RootUnit:
+		unit := e.GetUnit(node.Params[rule.Param])
+		if unit == "" {
+			return ""
+		}
+		degree := rule.Degree
+		if degree == 0 {
+			var ok bool
+			if degree, ok = e.literalDegree(node.Params[0]); !ok {
+				return ""
+			}
+		}
+		if degree == 1 {
+			return unit
+		}
+		return e.UnitLibrary.GetOperatorResult(unit, strconv.Itoa(degree), "root", true)
+	case PowerUnit:
+		unit := e.GetUnit(node.Params[rule.Param])
+		if unit == "" || rule.Degree == 1 {
+			return unit
+		}
+		return e.UnitLibrary.GetOperatorResult(unit, strconv.Itoa(rule.Degree), "^", true)
 	}
 	return ""
 }
 
+// Returns an error if the function does not allow the units of the parameters
+func (e *Environment) checkUnits(rule UnitRule, node *ASTFunction) error {
+	if rule.Kind != RequiresDimensionless {
+		return nil
+	}
+	for _, param := range node.Params {
+		if unit := e.GetUnit(param); unit != "" {
+			return fmt.Errorf("expected a number without a unit, got %v", e.UnitLibrary.GetUnitDisplayName(unit))
+		}
+	}
+	return nil
+}
+
 func (e *Environment) MakeLatexExpression(root ASTNode) (string, error) {
 	switch node := root.(type) {
 	case *ASTUnitOverride:
 		return e.formatUnitOverride(node)
 	case *ASTLiteral:
+		if e.symbols[node.Value] {
+			return e.Formatter.FormatVar(node.Value), nil
+		}
 		val, unit, err := e.parseLiteral(node)
 		if err != nil {
 			return "", err
 		}
+		// lists and matrices are too long to write every time they are used
+		switch val.(type) {
+		case List, Matrix:
+			return e.Formatter.FormatVar(node.Value), nil
+		}
 		// only do comment on result
 		return e.Formatter.FormatNumber(val, -1, e.UnitLibrary.GetUnitDisplayName(unit), ""), nil
+	case *ASTList, *ASTMatrix:
+		val, err := e.Evaluate(node)
+		if err != nil {
+			return "", err
+		}
+		return e.Formatter.FormatNumber(val, -1, e.UnitLibrary.GetUnitDisplayName(e.GetUnit(node)), ""), nil
 	case *ASTComment:
+		if len(e.symbols) > 0 {
+			return e.MakeLatexExpression(node.Child)
+		}
 
 		res, err := e.Evaluate(root)
 		if err != nil {
@@ -68,8 +197,19 @@ func (e *Environment) MakeLatexExpression(root ASTNode) (string, error) {
 		return e.Formatter.FormatNumber(res, precision, e.UnitLibrary.GetUnitDisplayName(e.GetUnit(node)), ""), nil
 
 		//return e.MakeLatexExpression(node.Child)
+	case *ASTPercent:
+		res, err := e.MakeLatexExpression(node.Child)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := node.Child.(*ASTOperator); ok {
+			res = e.Formatter.FormatParenthesie(res)
+		}
+		return res + "\\%", nil
 	case *ASTVarSetter:
 		return e.MakeLatexExpression(node.Child)
+	case *ASTFuncSetter:
+		return "", fmt.Errorf("function '%v' can only be defined at the start of a calculation", node.Name)
 	case *ASTOperator:
 		return e.formatOperator(node)
 	case *ASTFunction:
@@ -78,11 +218,39 @@ func (e *Environment) MakeLatexExpression(root ASTNode) (string, error) {
 	return "", errors.New("invalid AST node")
 }
 
-func (e *Environment) MakeLatexCalculation(root ASTNode) (string, error) {
+// Formats root with the given variables written by name, like the body of a function
+func (e *Environment) FormatSymbolic(root ASTNode, symbols ...string) (string, error) {
+	old := e.symbols
+	e.symbols = make(map[string]bool)
+	for k := range old {
+		e.symbols[k] = true
+	}
+	for _, s := range symbols {
+		e.symbols[s] = true
+	}
+	defer func() { e.symbols = old }()
+	return e.MakeLatexExpression(root)
+}
+
+// Whether variables are being written by name, like in a function definition, so the expression can not be evaluated
+func (e *Environment) Symbolic() bool {
+	return len(e.symbols) > 0
+}
+
+func symbolNodes(names []string) []ASTNode {
+	res := make([]ASTNode, len(names))
+	for i, n := range names {
+		res[i] = &ASTLiteral{Value: n}
+	}
+	return res
+}
+
+// Formats the calculation as one line, with the result of the calculation
+func (e *Environment) MakeLatexCalculation(root ASTNode) (string, Result, error) {
 	// IMPORTANT evaluating first, since it might introduce new variables that could be needed for formatting
 	res, err := e.Evaluate(root)
 	if err != nil {
-		return "", err
+		return "", Result{}, err
 	}
 	node, ok := root.(*ASTComment)
 	comment := ""
@@ -90,12 +258,14 @@ func (e *Environment) MakeLatexCalculation(root ASTNode) (string, error) {
 	if ok {
 		comment, precision, err = commentData(node.Content)
 		if err != nil {
-			return "", err
+			return "", Result{}, err
 		}
 		root = node.Child
 	}
+	result := Result{VariableValue: VariableValue{Value: res, Unit: e.GetUnit(root)}, Precision: precision, Comment: comment}
 	ok = true
 	check := root
+	name := ""
 	for ok {
 		ok = false
 		switch r := check.(type) {
@@ -104,27 +274,96 @@ func (e *Environment) MakeLatexCalculation(root ASTNode) (string, error) {
 			ok = true
 		case *ASTVarSetter:
 			check = r.Child
+			name = r.VarName
 			ok = true
 		case *ASTUnitOverride:
 			check = r.Child
 			ok = true
 		}
 	}
-	if _, ok = check.(*ASTLiteral); ok {
-		return "", nil
+	unit := e.UnitLibrary.GetUnitDisplayName(e.GetUnit(root))
+	formatted := e.Formatter.FormatNumber(res, precision, unit, comment)
+	switch c := check.(type) {
+	case *ASTLiteral:
+		return "", result, nil
+	case *ASTList, *ASTMatrix:
+		// data is shown as a table named by the variable, without rounding the data
+		if name != "" {
+			return e.Formatter.FormatLine(e.Formatter.FormatVar(name), e.Formatter.FormatNumber(res, 10, unit, comment)), result, nil
+		}
+	case *ASTFunction:
+		steps, err := e.functionSteps(c)
+		if err != nil {
+			return "", Result{}, err
+		}
+		uncertainty, err := e.uncertaintySteps(c)
+		if err != nil {
+			return "", Result{}, err
+		}
+		steps = append(steps, uncertainty...)
+		formatted = strings.Join(append(steps, formatted), " = ")
+	case *ASTOperator:
+		steps, err := e.operatorSteps(c)
+		if err != nil {
+			return "", Result{}, err
+		}
+		uncertainty, err := e.uncertaintySteps(c)
+		if err != nil {
+			return "", Result{}, err
+		}
+		steps = append(steps, uncertainty...)
+		formatted = strings.Join(append(steps, formatted), " = ")
 	}
 	expr, err := e.MakeLatexExpression(root)
 	if err != nil {
-		return "", err
+		return "", Result{}, err
 	}
-	unit := e.UnitLibrary.GetUnitDisplayName(e.GetUnit(root))
-	return e.Formatter.FormatLine(expr, e.Formatter.FormatNumber(res, precision, unit, comment)), nil
+	return e.Formatter.FormatLine(expr, formatted), result, nil
+}
+
+func (e *Environment) functionSteps(node *ASTFunction) ([]string, error) {
+	fun, err := e.getFunction(node)
+	if err != nil {
+		return nil, err
+	}
+	if fun.Special != nil {
+		return fun.Special.Steps(e, node.Params)
+	}
+	if fun.Steps == nil {
+		return nil, nil
+	}
+	args := make([]Value, len(node.Params))
+	for i, param := range node.Params {
+		args[i], err = e.Evaluate(param)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fun.Steps(e.Formatter, args), nil
+}
+
+func (e *Environment) operatorSteps(node *ASTOperator) ([]string, error) {
+	op, ok := e.Operators[node.Operator]
+	if !ok || op.Steps == nil {
+		return nil, nil
+	}
+	l, err := e.Evaluate(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.Evaluate(node.Right)
+	if err != nil {
+		return nil, err
+	}
+	return op.Steps(e.Formatter, l, r), nil
 }
 
 func (e *Environment) MakeMultilineCalculation(root ASTNode) ([]string, error) {
 	switch node := root.(type) {
 	case *ASTUnitOverride:
 		return e.MakeMultilineCalculation(node.Child)
+	case *ASTPercent:
+		return e.MakeMultilineCalculation(node.Child)
 	case *ASTLiteral:
 		return nil, nil
 	case *ASTComment:
@@ -132,7 +371,7 @@ func (e *Environment) MakeMultilineCalculation(root ASTNode) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
-		line, err := e.MakeLatexCalculation(node)
+		line, _, err := e.MakeLatexCalculation(node)
 		if err != nil {
 			return nil, err
 		}
@@ -157,7 +396,15 @@ func (e *Environment) MakeMultilineCalculation(root ASTNode) ([]string, error) {
 			return l, nil
 		}
 		return append(l, r...), nil
+	case *ASTList:
+		return e.MakeMultilineCalculation(&ASTFunction{Params: node.Items})
+	case *ASTMatrix:
+		return e.MakeMultilineCalculation(&ASTFunction{Params: node.Items()})
 	case *ASTFunction:
+		// parameters of special functions can not always be evaluated on their own
+		if fun, err := e.getFunction(node); err == nil && fun.Special != nil {
+			return nil, nil
+		}
 		res := make([]string, 0)
 		for _, param := range node.Params {
 			lines, err := e.MakeMultilineCalculation(param)
@@ -181,14 +428,22 @@ func (e *Environment) formatFunction(node *ASTFunction) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	replacements := make([]string, 0, len(node.Params)*2)
+	if fun.Special != nil {
+		return fun.Special.Format(e, node.Params)
+	}
+	replacements := make([]string, 0, len(node.Params)*4)
 	for i, param := range node.Params {
 		fParam, err := e.MakeLatexExpression(param)
 		if err != nil {
 			return "", err
 		}
-		replacements = append(replacements, "@"+fmt.Sprint(i))
-		replacements = append(replacements, fParam)
+		wrapped := fParam
+		// powers bind tighter than anything they can be next to
+		if op, ok := param.(*ASTOperator); ok && op.Operator != "^" {
+			wrapped = "(" + fParam + ")"
+		}
+		replacements = append(replacements, fmt.Sprintf("@(%v)", i), wrapped)
+		replacements = append(replacements, "@"+fmt.Sprint(i), fParam)
 	}
 	return strings.NewReplacer(replacements...).Replace(fun.Latex), nil
 }
@@ -206,6 +461,13 @@ func (e *Environment) formatOperator(node *ASTOperator) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	// written as a*(1+8%) so it is clear that it is 8% of a, except for 1-8% which is the same either way
+	if lit, ok := node.Left.(*ASTLiteral); PercentChange(node) && !(ok && lit.Value == "1") {
+		if lOp, ok := node.Left.(*ASTOperator); ok && getValue(lOp.Operator, e.OperatorPowers) < getValue("*", e.OperatorPowers) {
+			lRes = e.Formatter.FormatParenthesie(lRes)
+		}
+		return fmt.Sprintf("%v\\cdot(1%v%v)", lRes, node.Operator, rRes), nil
+	}
 	l, r := e.needParenthesis(node)
 	if l && op.ParenthesisLeft {
 		lRes = e.Formatter.FormatParenthesie(lRes)
@@ -217,10 +479,23 @@ func (e *Environment) formatOperator(node *ASTOperator) (string, error) {
 }
 
 func (e *Environment) formatUnitOverride(node *ASTUnitOverride) (string, error) {
+	if e.imaginary(node.Unit) {
+		res, err := e.MakeLatexExpression(node.Child)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := node.Child.(*ASTOperator); ok {
+			res = e.Formatter.FormatParenthesie(res)
+		}
+		return res + e.Settings.Imaginary, nil
+	}
 	literal, ok := node.Child.(*ASTLiteral)
 	if !ok {
 		return e.MakeLatexExpression(node.Child)
 	}
+	if e.symbols[literal.Value] {
+		return e.Formatter.FormatVar(literal.Value), nil
+	}
 	val, _, err := e.parseLiteral(literal)
 	if err != nil {
 		return "", err
@@ -238,9 +513,21 @@ func (e *Environment) needParenthesis(op *ASTOperator) (left bool, right bool) {
 	if ok {
 		right = getValue(rOp.Operator, e.OperatorPowers) <= getValue(op.Operator, e.OperatorPowers)
 	}
+	// negative numbers are written as (-2)^2 and 3*(-2)
+	if negated(op.Left) && op.Operator == "^" {
+		left = true
+	}
+	if negated(op.Right) {
+		right = true
+	}
 	return
 }
 
+func negated(node ASTNode) bool {
+	fun, ok := node.(*ASTFunction)
+	return ok && fun.Name == "neg"
+}
+
 func commentData(comment string) (string, int, error) {
 	split := strings.Index(comment, ":")
 	if split == -1 {
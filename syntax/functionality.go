@@ -1,11 +1,172 @@
 package syntax
 
-import "strings"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/eliiasg/mdcalc/util"
+)
 
 type Function struct {
 	Execute func([]float64) (float64, error)
-	// Use @i where 'i' for the formatted parameter staring at i = 0
+	// Used instead of Execute when set, for functions that take or return other values than numbers
+	ExecuteValue func([]Value) (Value, error)
+	// Used instead of the above when a parameter is complex or complex numbers are turned on, or when it is the only one set
+	ExecuteComplex func([]complex128) (complex128, error)
+	// Used when a parameter is an interval, functions without it do not support intervals
+	ExecuteInterval func([]Interval) (Interval, error)
+	// Use @i where 'i' for the formatted parameter staring at i = 0,
+	// and @(i) for the parameter in parenthesis when it is a calculation like a+b, but not a power like a^2
 	Latex string
+	// Optional intermediate steps shown between the function and its result, like the sum and division for a mean
+	Steps func(f Formatter, args []Value) []string
+	// How the unit of the result is found from the units of the parameters, by default the result has no unit
+	Unit UnitRule
+	// Used instead of everything above when set
+	Special SpecialFunction
+	// Only set for user defined functions
+	Params []string
+	Body   ASTNode
+}
+
+// How the unit of a function result is found from the units of its parameters
+type UnitRule struct {
+	Kind UnitKind
+	// the parameter the unit comes from, for SameUnit, RootUnit and PowerUnit
+	Param int
+	// for RootUnit, like 2 for sqrt, 0 to use the value of the first parameter like in root(3, x), and the exponent for PowerUnit
+	Degree int
+}
+
+type UnitKind int
+
+const (
+	// the result has no unit, whatever the units of the parameters are
+	Dimensionless UnitKind = iota
+	// the result has the unit of the parameter, like for floor(x)
+	SameUnit
+	// the result has the unit that raised to the degree is the unit of the parameter, like m for sqrt(16 m²).
+	// It is found with the unit library like the result of an operator, with the operator root and the degree as right side
+	RootUnit
+	// no parameter can have a unit, like for ln(x), the result has no unit
+	RequiresDimensionless
+	// the result has the unit of the parameter raised to the degree, like m² for var(x) of lengths.
+	// It is found with the unit library like the result of the operator ^
+	PowerUnit
+)
+
+// For functions that need their parameters unevaluated, like sum(i, 1, n, expr) where i is set while evaluating expr
+type SpecialFunction interface {
+	Evaluate(e *Environment, params []ASTNode) (Value, error)
+	Format(e *Environment, params []ASTNode) (string, error)
+	// may return nil if there are no intermediate steps
+	Steps(e *Environment, params []ASTNode) ([]string, error)
+}
+
+// Special functions can implement this if their result has a unit, otherwise it has no unit
+type SpecialUnit interface {
+	Unit(e *Environment, params []ASTNode) string
+}
+
+// Special functions can implement this if they can be replaced by an expression when used in a function definition,
+// like g(x) = diff(f, x) where the body becomes the derivative of f
+// Expand may return nil if it can not be expanded
+type SpecialExpand interface {
+	Expand(e *Environment, params []ASTNode) (ASTNode, error)
+}
+
+type Settings struct {
+	// largest amount of terms written out for sums and products, 0 to never write them out
+	ExpandTerms int
+	// trigonometry uses radians instead of degrees, numbers with the unit deg or rad are always in that unit
+	Radians bool
+	// letter of the imaginary unit (i or j), empty when complex numbers are turned off
+	Imaginary string
+	// complex numbers are written in polar form
+	Polar bool
+	// calculations with uncertain numbers show the propagation formula as a step
+	ShowPropagation bool
+	// lists with two numbers like [2.5, 2.7] are intervals
+	Intervals bool
+	// every subproblem has its own variables, which start as the variables of the file scope
+	ScopedSubproblems bool
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		ExpandTerms: 6,
+	}
+}
+
+// Sets a setting from its name and value as written in the project config or an S line, like "angles rad"
+func (s *Settings) Set(name, value string) error {
+	switch name {
+	case "expand":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return errors.New("expand must be a number of terms, 0 to never write out terms")
+		}
+		s.ExpandTerms = n
+	case "angles":
+		switch value {
+		case "deg":
+			s.Radians = false
+		case "rad":
+			s.Radians = true
+		default:
+			return errors.New("angles must be either deg or rad")
+		}
+	case "complex":
+		switch value {
+		case "off":
+			s.Imaginary, s.Polar = "", false
+		case "i", "j":
+			s.Imaginary, s.Polar = value, false
+		case "polar":
+			s.Polar = true
+			if s.Imaginary == "" {
+				s.Imaginary = "i"
+			}
+		default:
+			return errors.New("complex must be either off, i, j or polar")
+		}
+	case "intervals":
+		switch value {
+		case "on":
+			s.Intervals = true
+		case "off":
+			s.Intervals = false
+		default:
+			return errors.New("intervals must be either on or off")
+		}
+	case "propagation":
+		switch value {
+		case "show":
+			s.ShowPropagation = true
+		case "hide":
+			s.ShowPropagation = false
+		default:
+			return errors.New("propagation must be either show or hide")
+		}
+	case "scopes":
+		switch value {
+		case "subproblem":
+			s.ScopedSubproblems = true
+		case "file":
+			s.ScopedSubproblems = false
+		default:
+			return errors.New("scopes must be either file or subproblem")
+		}
+	default:
+		return fmt.Errorf("unknown setting '%v'", name)
+	}
+	return nil
 }
 
 // Only operators that expect 2 arguments are supported.
@@ -19,10 +180,20 @@ type Operator struct {
 	ParenthesisRight bool
 	// Useful for some unit management stuff
 	OrderMatters bool
+	// Used instead of Execute when either side is a complex number, operators without it do not support complex numbers
+	ExecuteComplex func(complex128, complex128) (complex128, error)
+	// The result never has a unit, like for comparisons
+	Unitless bool
+	// Used when either side is an interval, operators without it do not support intervals
+	ExecuteInterval func(Interval, Interval) (Interval, error)
+	// Used when either side is another value than a number, like a matrix, or when Execute is not set
+	ExecuteValue func(Value, Value) (Value, error)
+	// Optional intermediate steps shown between the calculation and its result, like a matrix sum element by element
+	Steps func(f Formatter, l, r Value) []string
 }
 
 type VariableValue struct {
-	Value float64
+	Value Value
 	Unit  string
 }
 
@@ -33,12 +204,17 @@ type UnitLibrary interface {
 
 type Formatter interface {
 	FormatLine(expr, res string) string
-	// precision -1 for number in expression
-	FormatNumber(num float64, precision int, unit, comment string) string
+	// precision -1 for number in expression, num can be any Value
+	FormatNumber(num Value, precision int, unit, comment string) string
 	FormatVar(name string) string
 	FormatParenthesie(expr string) string
 }
 
+// whether the unit is the imaginary unit, so 2i is a complex number when complex numbers are turned on
+func (e *Environment) imaginary(unit string) bool {
+	return e.Settings.Imaginary != "" && (unit == "i" || unit == "j")
+}
+
 type Environment struct {
 	Operators map[string]Operator
 	// Name, Param amount
@@ -47,35 +223,319 @@ type Environment struct {
 	OperatorPowers map[string]int
 	Formatter      Formatter
 	UnitLibrary    UnitLibrary
+	Settings       Settings
+	// functions like solve and integrate stop with its error when it is done, nil never stops
+	Context context.Context
+	// variables that are formatted by name instead of value, like function parameters
+	symbols map[string]bool
+	// amount of nested user defined function calls
+	depth int
+	// variables declared with const, which can not be set again
+	constants map[string]bool
+	// variables that have been used in a calculation, for finding unused variables
+	read map[string]bool
+	// variables, constants and functions every scope starts with, nil until the first scope
+	fileScope     map[string]VariableValue
+	fileConstants map[string]bool
+	fileFunctions map[string]map[int]Function
 }
 
-func (e *Environment) WriteCalculation(code string, sb *strings.Builder) error {
-	tokens := Tokenize(code)
-	tree, err := GenerateAst(tokens)
+// The result of a calculation, with the precision and comment it was rendered with
+type Result struct {
+	VariableValue
+	Precision int
+	Comment   string
+	// the variable set by the calculation, if any
+	Name string
+	// like variables that are set again with a different unit
+	Warnings []string
+}
+
+// Writes the calculation, calculations starting with const, like const g = 9.82, declare a variable that can not be set again,
+// and calculations starting with export also set the variable in the file scope
+func (e *Environment) WriteCalculation(code string, sb *strings.Builder) (Result, error) {
+	code, export := strings.CutPrefix(strings.TrimSpace(code), "export ")
+	code, constant := strings.CutPrefix(strings.TrimSpace(code), "const ")
+	tree, err := e.parseCalculation(code)
 	if err != nil {
-		return err
+		return Result{}, err
+	}
+	if fs, ok := tree.(*ASTFuncSetter); ok {
+		if constant || export {
+			return Result{}, errors.New("only variables can be declared const or exported, like export g = 9.82")
+		}
+		return Result{}, e.writeFunctionDefinition(fs, sb)
 	}
-	tree = ResolveOperatorChains(tree, e.OperatorPowers)
+	name := ""
 	if vs, ok := tree.(*ASTVarSetter); ok {
+		name = vs.VarName
 		if co, ok := vs.Child.(*ASTComment); ok {
 			vs.Child = co.Child
 			co.Child = vs
 			tree = co
 		}
 	}
-	_, ok := tree.(*ASTComment)
+	if (constant || export) && name == "" {
+		return Result{}, errors.New("only variables can be declared const or exported, like export g = 9.82")
+	}
+	root, ok := tree.(*ASTComment)
 	if !ok {
-		tree = &ASTComment{Child: tree}
+		root = &ASTComment{Child: tree}
 	}
-	lines, err := e.MakeMultilineCalculation(tree)
+	units := e.units()
+	lines, err := e.MakeMultilineCalculation(root.Child)
 	if err != nil {
-		return err
+		return Result{}, err
+	}
+	// the whole calculation is the last line
+	line, res, err := e.MakeLatexCalculation(root)
+	if err != nil {
+		return Result{}, err
 	}
+	lines = append(lines, line)
 	sb.WriteString("$$\n\\begin{align*}")
 	for _, line := range lines {
 		sb.WriteRune('\n')
 		sb.WriteString(line)
 	}
 	sb.WriteString("\n\\end{align*}\n$$")
+	if constant {
+		if e.constants == nil {
+			e.constants = make(map[string]bool)
+		}
+		e.constants[name] = true
+	}
+	if export {
+		e.export(name)
+	}
+	res.Name = name
+	res.Warnings = e.unitWarnings(units)
+	return res, nil
+}
+
+func (e *Environment) writeFunctionDefinition(node *ASTFuncSetter, sb *strings.Builder) error {
+	comment := ""
+	if co, ok := node.Child.(*ASTComment); ok {
+		comment, _, _ = commentData(co.Content)
+		node.Child = co.Child
+	}
+	if err := e.DefineFunction(node); err != nil {
+		return err
+	}
+	fun, _ := e.getFunction(&ASTFunction{Name: node.Name, Params: make([]ASTNode, len(node.Params))})
+	lhs, err := e.FormatSymbolic(&ASTFunction{Name: node.Name, Params: symbolNodes(node.Params)}, node.Params...)
+	if err != nil {
+		return err
+	}
+	rhs, err := e.FormatSymbolic(fun.Body, node.Params...)
+	if err != nil {
+		return err
+	}
+	// show what the body was expanded from, like f'(x) for diff(f, x)
+	if fun.Body != node.Child {
+		from, err := e.FormatSymbolic(node.Child, node.Params...)
+		if err != nil {
+			return err
+		}
+		rhs = from + " = " + rhs
+	}
+	if comment != "" {
+		rhs += fmt.Sprintf("\\textit{ (%v)}", comment)
+	}
+	sb.WriteString("$$\n\\begin{align*}\n")
+	sb.WriteString(e.Formatter.FormatLine(lhs, rhs))
+	sb.WriteString("\n\\end{align*}\n$$")
+	return nil
+}
+
+// Sets the variable and writes it like a calculation, for values that are not from an expression, like loaded data
+func (e *Environment) WriteVariable(name string, val VariableValue, sb *strings.Builder) (Result, error) {
+	if !util.StrIsAlpha(name) {
+		return Result{}, fmt.Errorf("invalid variable name '%v'", name)
+	}
+	units := e.units()
+	if err := e.SetVariable(name, val); err != nil {
+		return Result{}, err
+	}
+	sb.WriteString("$$\n\\begin{align*}\n")
+	sb.WriteString(e.Formatter.FormatLine(e.Formatter.FormatVar(name), e.Formatter.FormatNumber(val.Value, 10, e.UnitLibrary.GetUnitDisplayName(val.Unit), "")))
+	sb.WriteString("\n\\end{align*}\n$$")
+	return Result{VariableValue: val, Precision: 10, Name: name, Warnings: e.unitWarnings(units)}, nil
+}
+
+// Sets the variable, setting a constant is an error
+func (e *Environment) SetVariable(name string, val VariableValue) error {
+	if e.constants[name] {
+		return fmt.Errorf("cannot set '%v', it is declared const", name)
+	}
+	e.VariableValues[name] = val
 	return nil
 }
+
+// the units of the variables, to find the variables that are set again with a different unit
+func (e *Environment) units() map[string]string {
+	res := make(map[string]string, len(e.VariableValues))
+	for name, val := range e.VariableValues {
+		res[name] = val.Unit
+	}
+	return res
+}
+
+// warnings for the variables that have another unit than in units, sorted by name
+func (e *Environment) unitWarnings(units map[string]string) []string {
+	names := make([]string, 0)
+	for name, val := range e.VariableValues {
+		if old, ok := units[name]; ok && old != val.Unit {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	res := make([]string, len(names))
+	for i, name := range names {
+		res[i] = fmt.Sprintf("'%v' is redefined with %v, it had %v", name, describeUnit(e.VariableValues[name].Unit), describeUnit(units[name]))
+	}
+	return res
+}
+
+func describeUnit(unit string) string {
+	if unit == "" {
+		return "no unit"
+	}
+	return "the unit " + unit
+}
+
+// Starts a new scope, like for a subproblem, where only the variables and functions of the file scope are set.
+// The file scope is the variables and functions when the first scope starts, and variables that are exported later.
+// Variables that are not in the file scope count as unused again
+func (e *Environment) EnterScope() {
+	if e.fileScope == nil {
+		e.fileScope = maps.Clone(e.VariableValues)
+		e.fileConstants = maps.Clone(e.constants)
+		e.fileFunctions = cloneFunctions(e.Functions)
+	}
+	e.VariableValues = maps.Clone(e.fileScope)
+	e.constants = maps.Clone(e.fileConstants)
+	e.Functions = cloneFunctions(e.fileFunctions)
+	for name := range e.read {
+		if _, ok := e.fileScope[name]; !ok {
+			delete(e.read, name)
+		}
+	}
+}
+
+// functions are defined in the map of their name, so those are cloned too
+func cloneFunctions(funs map[string]map[int]Function) map[string]map[int]Function {
+	res := make(map[string]map[int]Function, len(funs))
+	for name, f := range funs {
+		res[name] = maps.Clone(f)
+	}
+	return res
+}
+
+// sets the variable in the file scope, so it is also set in later scopes
+func (e *Environment) export(name string) {
+	if e.fileScope == nil {
+		return
+	}
+	e.fileScope[name] = e.VariableValues[name]
+	if e.constants[name] {
+		if e.fileConstants == nil {
+			e.fileConstants = make(map[string]bool)
+		}
+		e.fileConstants[name] = true
+	}
+}
+
+// Whether the variable has been used in a calculation
+func (e *Environment) Used(name string) bool {
+	return e.read[name]
+}
+
+// Evaluates code and formats the result as inline math, for use in text
+func (e *Environment) MakeInlineCalculation(code string, precision int) (string, error) {
+	res, err := e.Calculate(code)
+	if err != nil {
+		return "", err
+	}
+	unit := e.UnitLibrary.GetUnitDisplayName(res.Unit)
+	return "$" + e.Formatter.FormatNumber(res.Value, precision, unit, "") + "$", nil
+}
+
+// Evaluates code without formatting it
+func (e *Environment) Calculate(code string) (VariableValue, error) {
+	tree, err := e.parseCalculation(code)
+	if err != nil {
+		return VariableValue{}, err
+	}
+	res, err := e.Evaluate(tree)
+	if err != nil {
+		return VariableValue{}, err
+	}
+	return VariableValue{Value: res, Unit: e.GetUnit(tree)}, nil
+}
+
+// Evaluates root with the variable set to val, without changing the variable afterwards
+func (e *Environment) EvaluateWith(root ASTNode, variable string, val Value) (Value, error) {
+	// this is what functions evaluating many times use, so it is where long calculations are stopped
+	if e.Context != nil {
+		if err := e.Context.Err(); err != nil {
+			return nil, err
+		}
+	}
+	var res Value
+	err := e.With(variable, val, func() error {
+		var err error
+		res, err = e.Evaluate(root)
+		return err
+	})
+	return res, err
+}
+
+// Calls f with the variable set to val, and restores the variable afterwards
+func (e *Environment) With(variable string, val Value, f func() error) error {
+	old, defined := e.VariableValues[variable]
+	defer func() {
+		if defined {
+			e.VariableValues[variable] = old
+		} else {
+			delete(e.VariableValues, variable)
+		}
+	}()
+	e.VariableValues[variable] = VariableValue{Value: val}
+	return f()
+}
+
+// Evaluates code for every value of the variable, without changing the variable afterwards
+func (e *Environment) Sample(code, variable string, values []float64) ([]float64, error) {
+	tree, err := e.parseCalculation(code)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]float64, len(values))
+	failed := 0
+	for i, v := range values {
+		var val Value
+		val, err = e.EvaluateWith(tree, variable, v)
+		if err == nil {
+			res[i], err = Number(val)
+		}
+		// some points failing is expected, like dividing by zero
+		if err != nil {
+			res[i] = math.NaN()
+			failed++
+		}
+	}
+	if failed == len(values) {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (e *Environment) parseCalculation(code string) (ASTNode, error) {
+	tokens := Tokenize(code)
+	tree, err := GenerateAst(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveOperatorChains(tree, e.OperatorPowers), nil
+}
@@ -20,12 +20,24 @@ type TokenComma struct {
 	tokenImpl
 }
 
+// separates the rows of a matrix, like [1, 2; 3, 4]
+type TokenSemicolon struct {
+	tokenImpl
+}
+
 type TokenParenthesis struct {
 	tokenImpl
 	// closing if false
 	Opening bool
 }
 
+// [ or ], for lists
+type TokenBracket struct {
+	tokenImpl
+	// closing if false
+	Opening bool
+}
+
 // will be followed by start parenthesis, and closed with closing parenthesis
 type TokenFunc struct {
 	tokenImpl
@@ -48,6 +60,18 @@ type TokenOperator struct {
 	Operator string
 }
 
+// % after an expression, like 8%
+type TokenPercent struct {
+	tokenImpl
+	// followed by + or -, like 8% - 2, which could also be 8 divided by -2
+	Ambiguous bool
+}
+
+// - without anything on its left, like in -2 or 3*-2
+type TokenNeg struct {
+	tokenImpl
+}
+
 type TokenComment struct {
 	tokenImpl
 	Content string
@@ -58,13 +82,22 @@ type TokenVarSetter struct {
 	VarName string
 }
 
+// f(x, y) = ...
+type TokenFuncSetter struct {
+	tokenImpl
+	Name   string
+	Params []string
+}
+
 type tokenizerState struct {
 	res             []Token
 	wasNum          bool
 	wasAlpha        bool
 	readyForUnit    bool
 	handlingComment bool
-	curRes          strings.Builder
+	// inside a unit like [kr/h]
+	handlingUnit bool
+	curRes       strings.Builder
 }
 
 func Tokenize(prgm string) []Token {
@@ -72,12 +105,18 @@ func Tokenize(prgm string) []Token {
 		res: make([]Token, 0),
 	}
 	for _, c := range "(" + prgm + ") " {
+		if handleBracketUnit(state, c) {
+			continue
+		}
+		handlePercent(state, c)
 		handleNum(state, c)
 		handleUnit(state, c)
 		handleVarRef(state, c)
 		handleOperators(state, c)
 		handleVarAssign(state, c)
 		handleComma(state, c)
+		handleSemicolon(state, c)
+		handleBrackets(state, c)
 		// single responsibility principle in action
 		handleParenthesesAndComments(state, c)
 		state.wasNum = util.IsNum(c)
@@ -88,6 +127,18 @@ func Tokenize(prgm string) []Token {
 
 // All the following functions are a mess of spaghetti and side effects
 
+// % divides when followed by something to divide by, otherwise it is a percentage like in 8% or (1-8%).
+// Operators that can not start an expression, like * in 8%*2, also make it a percentage
+func handlePercent(s *tokenizerState, c rune) {
+	if s.handlingComment || len(s.res) == 0 || s.curRes.Len() > 0 || !strings.ContainsRune("*/%^+-=<>!),;]:", c) {
+		return
+	}
+	if op, ok := s.res[len(s.res)-1].(TokenOperator); ok && op.Operator == "%" {
+		s.res[len(s.res)-1] = TokenPercent{Ambiguous: c == '+' || c == '-'}
+		s.readyForUnit = true
+	}
+}
+
 func handleComma(s *tokenizerState, c rune) {
 	if !s.handlingComment && c == ',' {
 		s.res = append(s.res, TokenComma{})
@@ -95,30 +146,159 @@ func handleComma(s *tokenizerState, c rune) {
 	}
 }
 
+func handleSemicolon(s *tokenizerState, c rune) {
+	if !s.handlingComment && c == ';' {
+		s.res = append(s.res, TokenSemicolon{})
+		s.readyForUnit = false
+	}
+}
+
+func handleBrackets(s *tokenizerState, c rune) {
+	if s.handlingComment {
+		return
+	}
+	if c == '[' && s.readyForUnit {
+		// [ after an expression starts a unit like [kr/h]
+		s.handlingUnit = true
+		s.curRes.WriteRune(c)
+	} else if c == '[' {
+		s.res = append(s.res, TokenBracket{Opening: true})
+		s.readyForUnit = false
+	} else if c == ']' {
+		s.res = append(s.res, TokenBracket{Opening: false})
+		s.readyForUnit = true
+	}
+}
+
+// everything until ] is part of the unit
+func handleBracketUnit(s *tokenizerState, c rune) bool {
+	if !s.handlingUnit {
+		return false
+	}
+	s.curRes.WriteRune(c)
+	if c == ']' {
+		s.res = append(s.res, TokenUnit{Name: s.curRes.String()})
+		s.curRes.Reset()
+		s.handlingUnit = false
+	}
+	return true
+}
+
 func handleVarAssign(s *tokenizerState, c rune) {
 	if c != '=' || len(s.res) == 0 || s.handlingComment {
 		return
 	}
 	elem := s.res[len(s.res)-1]
 	switch t := elem.(type) {
+	// <=, >=, == and !=
+	case TokenOperator:
+		if len(t.Operator) == 1 && strings.Contains("<>=!", t.Operator) {
+			s.res[len(s.res)-1] = TokenOperator{Operator: t.Operator + "="}
+			return
+		}
+	// x == 3 at the start is not an assignment
+	case TokenVarSetter:
+		s.res[len(s.res)-1] = TokenLiteral{Value: t.VarName}
+		addOperator(s, c)
+		s.res[len(s.res)-1] = TokenOperator{Operator: "=="}
+		return
 	case TokenLiteral:
 		s.res[len(s.res)-1] = TokenVarSetter{VarName: t.Value}
 		s.readyForUnit = false
+		return
+	case TokenParenthesis:
+		if !t.Opening && handleFuncAssign(s) {
+			return
+		}
+	}
+	// after a parenthesis or unit it is an equation, like in solve(2*(x+1) = 10, x)
+	addOperator(s, c)
+}
+
+// whether the token at i is the first in a parenthesis, but not the first parameter of a function
+func atStart(s *tokenizerState, i int) bool {
+	if i < 1 {
+		return false
+	}
+	if p, ok := s.res[i-1].(TokenParenthesis); !ok || !p.Opening {
+		return false
+	}
+	if i < 2 {
+		return true
+	}
+	_, fun := s.res[i-2].(TokenFunc)
+	return !fun
+}
+
+// replaces 'f', '(', 'x', ',', 'y', ')' with a function setter
+func handleFuncAssign(s *tokenizerState) bool {
+	params := make([]string, 0)
+	i := len(s.res) - 2
+	for ; i >= 0; i-- {
+		switch t := s.res[i].(type) {
+		case TokenLiteral:
+			params = append([]string{t.Value}, params...)
+			continue
+		case TokenComma:
+			continue
+		case TokenParenthesis:
+			if !t.Opening {
+				return false
+			}
+		default:
+			return false
+		}
+		break
+	}
+	if i < 1 {
+		return false
+	}
+	fun, ok := s.res[i-1].(TokenFunc)
+	if !ok || !atStart(s, i-1) {
+		return false
 	}
+	s.res = append(s.res[:i-1], TokenFuncSetter{Name: fun.Name, Params: params})
+	s.readyForUnit = false
+	return true
 }
 
 func handleOperators(s *tokenizerState, c rune) {
-	if !s.readyForUnit || c == ' ' || c == '=' || c == ')' || c == ':' || c == ',' || util.IsAlpha(c) {
+	if c == '-' && !s.handlingComment && negates(s) {
+		s.res = append(s.res, TokenNeg{})
+		s.readyForUnit = false
+		return
+	}
+	if !s.readyForUnit || c == ' ' || c == '=' || c == ')' || c == ':' || c == ',' || c == ';' || c == '[' || c == ']' || util.IsAlpha(c) {
 		return
 	}
 	switch t := s.res[len(s.res)-1].(type) {
-	case TokenUnit, TokenLiteral:
+	case TokenUnit, TokenLiteral, TokenPercent:
 		addOperator(s, c)
 	case TokenParenthesis:
 		if !t.Opening {
 			addOperator(s, c)
 		}
+	case TokenBracket:
+		if !t.Opening {
+			addOperator(s, c)
+		}
+	}
+}
+
+// whether a - is a unary minus, because there is no expression on its left
+func negates(s *tokenizerState) bool {
+	if len(s.res) == 0 || s.curRes.Len() > 0 {
+		return false
+	}
+	switch t := s.res[len(s.res)-1].(type) {
+	case TokenParenthesis:
+		return t.Opening
+	case TokenBracket:
+		return t.Opening
+	case TokenOperator, TokenNeg, TokenComma, TokenSemicolon, TokenVarSetter, TokenFuncSetter:
+		return true
 	}
+	return false
 }
 
 func addOperator(s *tokenizerState, c rune) {
@@ -29,10 +29,14 @@ func ResolveOperatorChains(n ASTNode, values map[string]int) ASTNode {
 	switch node := n.(type) {
 	case *ASTUnitOverride:
 		node.Child = ResolveOperatorChains(node.Child, values)
+	case *ASTPercent:
+		node.Child = ResolveOperatorChains(node.Child, values)
 	case *ASTComment:
 		node.Child = ResolveOperatorChains(node.Child, values)
 	case *ASTVarSetter:
 		node.Child = ResolveOperatorChains(node.Child, values)
+	case *ASTFuncSetter:
+		node.Child = ResolveOperatorChains(node.Child, values)
 	case *ASTOperator:
 		node.Left = ResolveOperatorChains(node.Left, values)
 		node.Right = ResolveOperatorChains(node.Right, values)
@@ -40,6 +44,16 @@ func ResolveOperatorChains(n ASTNode, values map[string]int) ASTNode {
 		for i, param := range node.Params {
 			node.Params[i] = ResolveOperatorChains(param, values)
 		}
+	case *ASTList:
+		for i, item := range node.Items {
+			node.Items[i] = ResolveOperatorChains(item, values)
+		}
+	case *ASTMatrix:
+		for _, row := range node.Rows {
+			for i, item := range row {
+				row[i] = ResolveOperatorChains(item, values)
+			}
+		}
 	case *ASTOperatorChain:
 		r := generateInitalOperator(node)
 		r = sortOperators(r, values)
@@ -66,6 +80,12 @@ type ASTUnitOverride struct {
 	Child ASTNode
 }
 
+// expr%, the value of expr divided by 100 without a unit
+type ASTPercent struct {
+	astValImpl
+	Child ASTNode
+}
+
 type ASTComment struct {
 	astValImpl
 	Content string
@@ -78,6 +98,13 @@ type ASTVarSetter struct {
 	Child   ASTNode
 }
 
+type ASTFuncSetter struct {
+	astValImpl
+	Name   string
+	Params []string
+	Child  ASTNode
+}
+
 // Only allows operators with a left and right (so no negate or not operator)
 type ASTOperator struct {
 	astValImpl
@@ -100,6 +127,27 @@ type ASTFunction struct {
 	Params []ASTNode
 }
 
+// [a, b, c]
+type ASTList struct {
+	astValImpl
+	Items []ASTNode
+}
+
+// [a, b; c, d], every row has the same length
+type ASTMatrix struct {
+	astValImpl
+	Rows [][]ASTNode
+}
+
+// every item, row by row
+func (m *ASTMatrix) Items() []ASTNode {
+	res := make([]ASTNode, 0)
+	for _, row := range m.Rows {
+		res = append(res, row...)
+	}
+	return res
+}
+
 func resolveExpression(code []Token) (ASTNode, error) {
 	res := &ASTOperatorChain{
 		Operators: make([]string, 0),
@@ -113,10 +161,30 @@ func resolveExpression(code []Token) (ASTNode, error) {
 			if expr == nil {
 				return nil, fmt.Errorf("cannot have unit without expression, got unit '%v'", tok.Name)
 			}
+			unit := tok.Name
+			// written the same way as the units it is calculated from, so [h*kr] is the same as [kr*h]
+			if IsCompoundUnit(unit) {
+				parsed, err := ParseUnit(unit)
+				if err != nil {
+					return nil, err
+				}
+				unit = parsed.String()
+				if unit == "" {
+					unit = "None"
+				}
+			}
 			expr = &ASTUnitOverride{
-				Unit:  tok.Name,
+				Unit:  unit,
 				Child: expr,
 			}
+		case TokenPercent:
+			if expr == nil {
+				return nil, errors.New("expected expression before %")
+			}
+			if tok.Ambiguous {
+				return nil, errors.New("% followed by + or - is ambiguous, write (8%)-2 for a percentage or 8%(-2) to divide")
+			}
+			expr = &ASTPercent{Child: expr}
 		case TokenLiteral:
 			if expr != nil {
 				return nil, fmt.Errorf("expected operator or ) after expression, got literal '%v'", tok.Value)
@@ -130,7 +198,7 @@ func resolveExpression(code []Token) (ASTNode, error) {
 				return nil, errors.New("expected operator or ) after expression, got (")
 			}
 			next := closingIdx(code, i)
-			if next == -1 {
+			if p, ok := code[max(next, 0)].(TokenParenthesis); next == -1 || !ok || p.Opening {
 				return nil, errors.New("expected )")
 			}
 			var err error
@@ -139,6 +207,23 @@ func resolveExpression(code []Token) (ASTNode, error) {
 				return nil, err
 			}
 			i = next
+		case TokenBracket:
+			if !tok.Opening {
+				return nil, fmt.Errorf("unexpected ']'")
+			}
+			if expr != nil {
+				return nil, errors.New("expected operator or ) after expression, got [")
+			}
+			next := closingIdx(code, i)
+			if b, ok := code[max(next, 0)].(TokenBracket); next == -1 || !ok || b.Opening {
+				return nil, errors.New("expected ]")
+			}
+			var err error
+			expr, err = resolveList(code[i+1 : next])
+			if err != nil {
+				return nil, err
+			}
+			i = next
 		case TokenFunc:
 			if expr != nil {
 				return nil, errors.New("expected operator or ) after expression, got (")
@@ -153,16 +238,33 @@ func resolveExpression(code []Token) (ASTNode, error) {
 				return nil, err
 			}
 			i = next
+		case TokenNeg:
+			if expr != nil {
+				return nil, errors.New("unexpected -")
+			}
+			end, err := operandEnd(code, i+1)
+			if err != nil {
+				return nil, err
+			}
+			child, err := resolveExpression(code[i+1 : end])
+			if err != nil {
+				return nil, err
+			}
+			expr = &ASTFunction{Name: "neg", Params: []ASTNode{child}}
+			i = end
+			continue
 		case TokenOperator:
 			res.Values = append(res.Values, expr)
 			expr = nil
 			res.Operators = append(res.Operators, tok.Operator)
 		case TokenComment:
 			return nil, errors.New("unexpected ':' comments can only be at end of code or parenthesis")
-		case TokenVarSetter:
+		case TokenVarSetter, TokenFuncSetter:
 			return nil, errors.New("unexpected =")
 		case TokenComma:
 			return nil, errors.New("unexpected ,")
+		case TokenSemicolon:
+			return nil, errors.New("unexpected ;, it can only be used between the rows of a matrix like [1, 2; 3, 4]")
 		}
 		i++
 	}
@@ -188,19 +290,126 @@ func resolveFunc(code []Token) (ASTNode, error) {
 	if len(code) == 3 {
 		return funNode, nil
 	}
-	startidx := 2
-	for i, t := range code[1:] {
-		if _, ok := t.(TokenComma); !ok && i != len(code)-2 {
+	params := code[2 : len(code)-1]
+	if equationFunctions[fun.Name] {
+		params = equation(params)
+	}
+	items, err := resolveItems(params)
+	if err != nil {
+		return nil, err
+	}
+	funNode.Params = items
+	return funNode, nil
+}
+
+// functions where = in the first parameter is an equation, like solve(2*x = 10, x), instead of setting a variable
+var equationFunctions = map[string]bool{"solve": true}
+
+// the parameters with every = in the first parameter as the = operator
+func equation(code []Token) []Token {
+	res := make([]Token, 0, len(code)+1)
+	depth := 0
+	for i, t := range code {
+		switch tok := t.(type) {
+		case TokenParenthesis:
+			depth += openValue(tok.Opening)
+		case TokenBracket:
+			depth += openValue(tok.Opening)
+		case TokenComma:
+			if depth == 0 {
+				return append(res, code[i:]...)
+			}
+		case TokenVarSetter:
+			res = append(res, TokenLiteral{Value: tok.VarName}, TokenOperator{Operator: "="})
 			continue
 		}
-		ast, err := GenerateAst(code[startidx : i+1])
+		res = append(res, t)
+	}
+	return res
+}
+
+func resolveList(code []Token) (ASTNode, error) {
+	if len(code) == 0 {
+		return &ASTList{Items: make([]ASTNode, 0)}, nil
+	}
+	rows := splitRows(code)
+	if len(rows) == 1 {
+		items, err := resolveItems(code)
 		if err != nil {
 			return nil, err
 		}
-		startidx = i + 2
-		funNode.Params = append(funNode.Params, ast)
+		return &ASTList{Items: items}, nil
 	}
-	return funNode, nil
+	res := &ASTMatrix{Rows: make([][]ASTNode, len(rows))}
+	for i, row := range rows {
+		items, err := resolveItems(row)
+		if err != nil {
+			return nil, err
+		}
+		if i != 0 && len(items) != len(res.Rows[0]) {
+			return nil, errors.New("every row of a matrix must have the same length")
+		}
+		res.Rows[i] = items
+	}
+	return res, nil
+}
+
+// splits at semicolons that are not inside parenthesis or brackets
+func splitRows(code []Token) [][]Token {
+	res := make([][]Token, 0)
+	depth := 0
+	start := 0
+	for i, t := range code {
+		switch tok := t.(type) {
+		case TokenParenthesis:
+			depth += openValue(tok.Opening)
+		case TokenBracket:
+			depth += openValue(tok.Opening)
+		case TokenSemicolon:
+			if depth == 0 {
+				res = append(res, code[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, code[start:])
+}
+
+// splits at commas that are not inside parenthesis or brackets
+func resolveItems(code []Token) ([]ASTNode, error) {
+	res := make([]ASTNode, 0)
+	depth := 0
+	start := 0
+	for i, t := range code {
+		switch tok := t.(type) {
+		case TokenParenthesis:
+			depth += openValue(tok.Opening)
+		case TokenBracket:
+			depth += openValue(tok.Opening)
+		case TokenComma:
+			if depth != 0 {
+				continue
+			}
+			ast, err := GenerateAst(code[start:i])
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, ast)
+			start = i + 1
+		}
+	}
+	ast, err := GenerateAst(code[start:])
+	if err != nil {
+		return nil, err
+	}
+	return append(res, ast), nil
+}
+
+func openValue(opening bool) int {
+	if opening {
+		return 1
+	}
+	return -1
 }
 
 // Only finds length error
@@ -219,6 +428,13 @@ func strip(code []Token) (ASTNode, error) {
 	if res != nil {
 		return res, nil
 	}
+	res, err = resolveFuncSetter(code)
+	if err != nil {
+		return nil, err
+	}
+	if res != nil {
+		return res, nil
+	}
 	res, err = resolveComment(code)
 	if err != nil {
 		return nil, err
@@ -244,6 +460,22 @@ func resolveVarSetter(code []Token) (ASTNode, error) {
 	return nil, nil
 }
 
+// returns nil if no function setter
+func resolveFuncSetter(code []Token) (ASTNode, error) {
+	if setter, ok := code[0].(TokenFuncSetter); ok {
+		res, err := GenerateAst(code[1:])
+		if err != nil {
+			return nil, err
+		}
+		return &ASTFuncSetter{
+			Name:   setter.Name,
+			Params: setter.Params,
+			Child:  res,
+		}, nil
+	}
+	return nil, nil
+}
+
 // returns nil if no comment
 func resolveComment(code []Token) (ASTNode, error) {
 	idx := len(code) - 1
@@ -260,17 +492,65 @@ func resolveComment(code []Token) (ASTNode, error) {
 	return nil, nil
 }
 
+// parenthesis and brackets are counted together, so the caller must check the kind of the closing token
+// the end of what a unary minus negates, which is the next value with its unit and powers, so -2^2 is -(2^2)
+func operandEnd(code []Token, i int) (int, error) {
+	for {
+		for i < len(code) && isNeg(code[i]) {
+			i++
+		}
+		if i >= len(code) {
+			return 0, errors.New("expected expression after -")
+		}
+		switch code[i].(type) {
+		case TokenLiteral:
+		case TokenParenthesis, TokenBracket:
+			i = closingIdx(code, i)
+		case TokenFunc:
+			i = closingIdx(code, i+1)
+		default:
+			return 0, errors.New("expected expression after -")
+		}
+		if i == -1 {
+			return 0, errors.New("expected )")
+		}
+		i++
+		for i < len(code) && isSuffix(code[i]) {
+			i++
+		}
+		if i < len(code) {
+			if op, ok := code[i].(TokenOperator); ok && op.Operator == "^" {
+				i++
+				continue
+			}
+		}
+		return i, nil
+	}
+}
+
+func isNeg(t Token) bool {
+	_, ok := t.(TokenNeg)
+	return ok
+}
+
+// units and % belong to the value before them
+func isSuffix(t Token) bool {
+	switch t.(type) {
+	case TokenUnit, TokenPercent:
+		return true
+	}
+	return false
+}
+
 func closingIdx(code []Token, startIdx int) int {
 	val := 0
 	for i := startIdx; i < len(code); i++ {
 		tkn := code[i]
 		switch p := tkn.(type) {
 		case TokenParenthesis:
-			if p.Opening {
-				val++
-			} else {
-				val--
-			}
+			val += openValue(p.Opening)
+		case TokenBracket:
+			val += openValue(p.Opening)
 		}
 		if val == 0 {
 			return i
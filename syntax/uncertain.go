@@ -0,0 +1,170 @@
+package syntax
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Calls f with the values of args, and propagates the uncertainties of args to the result with first-order propagation,
+// so the uncertainty is the square root of the sum of (df/dx * uncertainty of x)^2 for every parameter x.
+// The result is only uncertain if one of args is
+func Propagate(f func([]float64) (float64, error), args []Value) (Value, error) {
+	values, uncertainties, err := uncertainArgs(args)
+	if err != nil {
+		return math.NaN(), err
+	}
+	res, err := f(values)
+	if err != nil {
+		return math.NaN(), err
+	}
+	if !slices.ContainsFunc(args, isUncertain) {
+		return res, nil
+	}
+	terms, err := propagationTerms(f, values, uncertainties)
+	if err != nil {
+		return math.NaN(), err
+	}
+	sum := 0.0
+	for _, t := range terms {
+		sum += math.Pow(t.derivative*t.uncertainty, 2)
+	}
+	return Uncertain{Value: res, Uncertainty: math.Sqrt(sum)}, nil
+}
+
+func uncertainArgs(args []Value) ([]float64, []float64, error) {
+	values := make([]float64, len(args))
+	uncertainties := make([]float64, len(args))
+	for i, arg := range args {
+		if u, ok := arg.(Uncertain); ok {
+			values[i], uncertainties[i] = u.Value, u.Uncertainty
+			continue
+		}
+		n, err := Number(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		values[i] = n
+	}
+	return values, uncertainties, nil
+}
+
+type propagationTerm struct {
+	// partial derivative of the result with respect to the parameter
+	derivative  float64
+	uncertainty float64
+}
+
+// the term of every uncertain parameter
+func propagationTerms(f func([]float64) (float64, error), values, uncertainties []float64) ([]propagationTerm, error) {
+	res := make([]propagationTerm, 0)
+	for i, u := range uncertainties {
+		if u == 0 {
+			continue
+		}
+		x := values[i]
+		h := 1e-6 * math.Max(1, math.Abs(x))
+		at := slices.Clone(values)
+		at[i] = x + h
+		a, err := f(at)
+		if err != nil {
+			return nil, err
+		}
+		at[i] = x - h
+		b, err := f(at)
+		if err != nil {
+			return nil, err
+		}
+		// the numeric derivative is only precise to around 8 digits, so 1.2 is not shown as 1.2000000001
+		res = append(res, propagationTerm{derivative: significant((a-b)/(2*h), 8), uncertainty: u})
+	}
+	return res, nil
+}
+
+func significant(x float64, digits int) float64 {
+	if x == 0 || math.IsNaN(x) || math.IsInf(x, 0) {
+		return x
+	}
+	scale := math.Pow10(digits - 1 - int(math.Floor(math.Log10(math.Abs(x)))))
+	return math.Round(x*scale) / scale
+}
+
+func isUncertain(v Value) bool {
+	_, ok := v.(Uncertain)
+	return ok
+}
+
+// the operator as a function of its sides, for propagating uncertainties
+func operatorFunc(node *ASTOperator, op Operator) func([]float64) (float64, error) {
+	return func(x []float64) (float64, error) {
+		r := x[1]
+		if PercentChange(node) {
+			r *= x[0]
+		}
+		return op.Execute(x[0], r)
+	}
+}
+
+// the propagation formula with the numbers inserted, like (2,5 ± sqrt((1,2·0,02)^2+(3·0,1)^2)),
+// or nothing if none of args are uncertain
+func (e *Environment) propagationStep(f func([]float64) (float64, error), args []Value) (string, error) {
+	if !slices.ContainsFunc(args, isUncertain) {
+		return "", nil
+	}
+	values, uncertainties, err := uncertainArgs(args)
+	if err != nil {
+		return "", err
+	}
+	res, err := f(values)
+	if err != nil {
+		return "", err
+	}
+	terms, err := propagationTerms(f, values, uncertainties)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, len(terms))
+	for i, t := range terms {
+		formatted[i] = fmt.Sprintf("(%v\\cdot %v)^{2}", e.Formatter.FormatNumber(t.derivative, -1, "", ""), e.Formatter.FormatNumber(t.uncertainty, -1, "", ""))
+	}
+	return e.Formatter.FormatParenthesie(fmt.Sprintf("%v\\pm\\sqrt{%v}", e.Formatter.FormatNumber(res, -1, "", ""), strings.Join(formatted, "+"))), nil
+}
+
+// the propagation step of the last operator or function of a calculation, when it is turned on
+func (e *Environment) uncertaintySteps(root ASTNode) ([]string, error) {
+	if !e.Settings.ShowPropagation {
+		return nil, nil
+	}
+	var f func([]float64) (float64, error)
+	var params []ASTNode
+	switch node := root.(type) {
+	case *ASTOperator:
+		op, ok := e.Operators[node.Operator]
+		if !ok || op.Execute == nil {
+			return nil, nil
+		}
+		f, params = operatorFunc(node, op), []ASTNode{node.Left, node.Right}
+	case *ASTFunction:
+		fun, err := e.getFunction(node)
+		if err != nil || fun.Execute == nil || fun.Special != nil || fun.Body != nil {
+			return nil, nil
+		}
+		f, params = fun.Execute, node.Params
+	default:
+		return nil, nil
+	}
+	args := make([]Value, len(params))
+	for i, param := range params {
+		var err error
+		args[i], err = e.Evaluate(param)
+		if err != nil {
+			return nil, err
+		}
+	}
+	step, err := e.propagationStep(f, args)
+	if err != nil || step == "" {
+		return nil, err
+	}
+	return []string{step}, nil
+}
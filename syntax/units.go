@@ -0,0 +1,229 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A unit written in brackets, like [kr/h] or [m/s^2], as the exponent of every base unit.
+// Units written without brackets, like DkkPHour, are a single base unit
+type CompoundUnit map[string]int
+
+// whether the unit was written in brackets
+func IsCompoundUnit(unit string) bool {
+	return strings.HasPrefix(unit, "[")
+}
+
+// Parses a unit, with or without brackets, like kr/h, [m/s^2] or J/(kg*K).
+// / only divides by the following base unit or parenthesis, so kg*m/s^2 is the same as m*kg*s^-2
+func ParseUnit(unit string) (CompoundUnit, error) {
+	if !IsCompoundUnit(unit) {
+		res := CompoundUnit{}
+		if unit != "" {
+			res[unit] = 1
+		}
+		return res, nil
+	}
+	p := &unitParser{src: []rune(strings.TrimSuffix(strings.TrimPrefix(unit, "["), "]"))}
+	res, err := p.product()
+	if err != nil {
+		return nil, fmt.Errorf("invalid unit %v: %v", unit, err)
+	}
+	if p.i < len(p.src) {
+		return nil, fmt.Errorf("invalid unit %v: unexpected '%c'", unit, p.src[p.i])
+	}
+	return res, nil
+}
+
+type unitParser struct {
+	src []rune
+	i   int
+}
+
+func (p *unitParser) peek() rune {
+	for p.i < len(p.src) && p.src[p.i] == ' ' {
+		p.i++
+	}
+	if p.i == len(p.src) {
+		return 0
+	}
+	return p.src[p.i]
+}
+
+// factors separated by *, / or spaces
+func (p *unitParser) product() (CompoundUnit, error) {
+	res, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		sign := 1
+		switch p.peek() {
+		case 0, ')':
+			return res, nil
+		case '/':
+			sign = -1
+			p.i++
+		case '*', '·':
+			p.i++
+		}
+		f, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		res = res.Mul(f.Pow(sign))
+	}
+}
+
+// a base unit, 1 or a parenthesis, optionally with a whole exponent
+func (p *unitParser) factor() (CompoundUnit, error) {
+	var res CompoundUnit
+	switch c := p.peek(); {
+	case c == '(':
+		p.i++
+		var err error
+		res, err = p.product()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, errors.New("expected )")
+		}
+		p.i++
+	case c == '1':
+		p.i++
+		res = CompoundUnit{}
+	case unicode.IsLetter(c) || c == '°' || c == '%':
+		start := p.i
+		for p.i < len(p.src) && (unicode.IsLetter(p.src[p.i]) || p.src[p.i] == '°' || p.src[p.i] == '%') {
+			p.i++
+		}
+		res = CompoundUnit{string(p.src[start:p.i]): 1}
+	case c == 0:
+		return nil, errors.New("expected a unit")
+	default:
+		return nil, fmt.Errorf("unexpected '%c'", c)
+	}
+	if p.peek() != '^' {
+		return res, nil
+	}
+	p.i++
+	start := p.i
+	if p.peek() == '-' {
+		p.i++
+	}
+	for p.i < len(p.src) && unicode.IsDigit(p.src[p.i]) {
+		p.i++
+	}
+	exp, err := strconv.Atoi(string(p.src[start:p.i]))
+	if err != nil {
+		return nil, errors.New("expected a whole exponent after ^")
+	}
+	return res.Pow(exp), nil
+}
+
+func (u CompoundUnit) Mul(other CompoundUnit) CompoundUnit {
+	res := CompoundUnit{}
+	for _, units := range []CompoundUnit{u, other} {
+		for name, exp := range units {
+			res[name] += exp
+			if res[name] == 0 {
+				delete(res, name)
+			}
+		}
+	}
+	return res
+}
+
+func (u CompoundUnit) Pow(exp int) CompoundUnit {
+	res := CompoundUnit{}
+	if exp == 0 {
+		return res
+	}
+	for name, e := range u {
+		res[name] = e * exp
+	}
+	return res
+}
+
+// the degree-th root, if every exponent is divisible by degree
+func (u CompoundUnit) Root(degree int) (CompoundUnit, bool) {
+	res := CompoundUnit{}
+	for name, e := range u {
+		if degree == 0 || e%degree != 0 {
+			return nil, false
+		}
+		res[name] = e / degree
+	}
+	return res, true
+}
+
+// the base units over and under the fraction line, sorted by name
+func (u CompoundUnit) parts() ([]string, []string) {
+	num, den := make([]string, 0), make([]string, 0)
+	for name, exp := range u {
+		if exp > 0 {
+			num = append(num, name)
+		} else {
+			den = append(den, name)
+		}
+	}
+	slices.Sort(num)
+	slices.Sort(den)
+	return num, den
+}
+
+// The unit as it is used by GetUnit, like [m/s^2], so equal units give the same string.
+// A unit without any base units is ""
+func (u CompoundUnit) String() string {
+	num, den := u.parts()
+	if len(num) == 0 && len(den) == 0 {
+		return ""
+	}
+	write := func(names []string, sign int) string {
+		if len(names) == 0 {
+			return "1"
+		}
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = name
+			if exp := u[name] * sign; exp != 1 {
+				parts[i] += "^" + strconv.Itoa(exp)
+			}
+		}
+		return strings.Join(parts, "*")
+	}
+	if len(den) == 0 {
+		return "[" + write(num, 1) + "]"
+	}
+	if len(den) > 1 {
+		return "[" + write(num, 1) + "/(" + write(den, -1) + ")]"
+	}
+	return "[" + write(num, 1) + "/" + write(den, -1) + "]"
+}
+
+// like \frac{\mathrm{m}}{\mathrm{s}^{2}}
+func (u CompoundUnit) Latex() string {
+	num, den := u.parts()
+	write := func(names []string, sign int) string {
+		if len(names) == 0 {
+			return "1"
+		}
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = "\\mathrm{" + name + "}"
+			if exp := u[name] * sign; exp != 1 {
+				parts[i] += "^{" + strconv.Itoa(exp) + "}"
+			}
+		}
+		return strings.Join(parts, "\\,")
+	}
+	if len(den) == 0 {
+		return write(num, 1)
+	}
+	return "\\frac{" + write(num, 1) + "}{" + write(den, -1) + "}"
+}
@@ -0,0 +1,108 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Result of evaluating an expression, either a float64 or one of the value types below
+type Value any
+
+// List of numbers, like [12, 15, 9, 22]
+type List []float64
+
+// Rows of numbers, like [1, 2; 3, 4], vectors are matrices with one column like [1; 2; 3]
+type Matrix [][]float64
+
+// Number with an uncertainty, like 9.81±0.02
+type Uncertain struct {
+	Value       float64
+	Uncertainty float64
+}
+
+// Every number from Lo to Hi, like [2.5, 2.7] when intervals are turned on
+type Interval struct {
+	Lo float64
+	Hi float64
+}
+
+// Returns v as a number, or an error if it is another kind of value
+func Number(v Value) (float64, error) {
+	if n, ok := v.(float64); ok {
+		return n, nil
+	}
+	if _, ok := v.(complex128); ok {
+		return math.NaN(), errors.New("expected a real number, got a complex number")
+	}
+	return math.NaN(), fmt.Errorf("expected a number, got %v", TypeName(v))
+}
+
+// Returns v as a complex number, numbers are complex numbers without an imaginary part
+func Complex(v Value) (complex128, error) {
+	switch n := v.(type) {
+	case float64:
+		return complex(n, 0), nil
+	case complex128:
+		return n, nil
+	}
+	return cmplx.NaN(), fmt.Errorf("expected a number, got %v", TypeName(v))
+}
+
+// Complex numbers without an imaginary part are just numbers
+func ComplexValue(c complex128) Value {
+	if imag(c) == 0 {
+		return real(c)
+	}
+	return c
+}
+
+// Returns v as an interval, numbers are intervals with the same lower and upper bound
+func Bounds(v Value) (Interval, error) {
+	switch n := v.(type) {
+	case float64:
+		return Interval{Lo: n, Hi: n}, nil
+	case Interval:
+		return n, nil
+	}
+	return Interval{}, fmt.Errorf("expected a number or an interval, got %v", TypeName(v))
+}
+
+func isInterval(v Value) bool {
+	_, ok := v.(Interval)
+	return ok
+}
+
+// numbers and complex numbers, which operators can use without ExecuteValue
+func isNumber(v Value) bool {
+	switch v.(type) {
+	case float64, complex128:
+		return true
+	}
+	return false
+}
+
+func isComplex(v Value) bool {
+	_, ok := v.(complex128)
+	return ok
+}
+
+// Name of the kind of value, for error messages
+func TypeName(v Value) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case complex128:
+		return "complex number"
+	case List:
+		return "list"
+	case Matrix:
+		return "matrix"
+	case Uncertain:
+		return "uncertain number"
+	case Interval:
+		return "interval"
+	}
+	return "unknown value"
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 )
 
 type Operation struct {
@@ -12,12 +13,16 @@ type Operation struct {
 	Operator            string
 }
 
+// the files in the project directory the units are saved in
 const (
-	namesFileName      = "units.txt"
-	operationsFileName = "operators.txt"
+	NamesFileName      = "units.txt"
+	OperationsFileName = "operators.txt"
 )
 
+// Safe to use from multiple goroutines, only one unit is prompted for at a time
 type SavedUnitLibrary struct {
+	// held while prompting, so the same unit is not prompted for twice
+	mu             sync.RWMutex
 	names          map[string]string
 	operations     map[Operation]string
 	namesPath      string
@@ -25,7 +30,7 @@ type SavedUnitLibrary struct {
 }
 
 func NewSavedUnitLib(dir string) (*SavedUnitLibrary, error) {
-	bytes, err := os.ReadFile(dir + "/" + namesFileName)
+	bytes, err := os.ReadFile(dir + "/" + NamesFileName)
 	var names map[string]string
 	if err == nil {
 		names, err = loadNames(string(bytes))
@@ -35,7 +40,7 @@ func NewSavedUnitLib(dir string) (*SavedUnitLibrary, error) {
 	} else {
 		names = make(map[string]string)
 	}
-	bytes, err = os.ReadFile(dir + "/" + operationsFileName)
+	bytes, err = os.ReadFile(dir + "/" + OperationsFileName)
 	var operations map[Operation]string
 	if err == nil {
 		operations, err = loadOperations(string(bytes))
@@ -48,8 +53,8 @@ func NewSavedUnitLib(dir string) (*SavedUnitLibrary, error) {
 	return &SavedUnitLibrary{
 		names:          names,
 		operations:     operations,
-		namesPath:      dir + "/" + namesFileName,
-		operationsPath: dir + "/" + operationsFileName,
+		namesPath:      dir + "/" + NamesFileName,
+		operationsPath: dir + "/" + OperationsFileName,
 	}, nil
 }
 
@@ -87,10 +92,18 @@ func (l *SavedUnitLibrary) GetUnitDisplayName(unit string) string {
 	if unit == "" {
 		return ""
 	}
+	l.mu.RLock()
 	res, ok := l.names[unit]
+	l.mu.RUnlock()
 	if ok {
 		return res
 	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// another goroutine may have prompted for it while waiting
+	if res, ok := l.names[unit]; ok {
+		return res
+	}
 	res = prompt(fmt.Sprintf("name unit '%v': ", unit))
 	l.names[unit] = res
 	append(l.namesPath, unit+" "+res)
@@ -101,15 +114,16 @@ func (l *SavedUnitLibrary) GetOperatorResult(left string, right string, operator
 	if operator == "%" {
 		operator = "/"
 	}
-	res, ok := l.operations[Operation{left, right, operator}]
+	l.mu.RLock()
+	res, ok := l.operation(left, right, operator, orderMatters)
+	l.mu.RUnlock()
 	if ok {
 		return res
 	}
-	if !orderMatters {
-		res, ok = l.operations[Operation{right, left, operator}]
-		if ok {
-			return res
-		}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if res, ok := l.operation(left, right, operator, orderMatters); ok {
+		return res
 	}
 	res = prompt(fmt.Sprintf("determine unit result of '%v' %v '%v': ", left, operator, right))
 	l.operations[Operation{left, right, operator}] = res
@@ -117,6 +131,15 @@ func (l *SavedUnitLibrary) GetOperatorResult(left string, right string, operator
 	return res
 }
 
+func (l *SavedUnitLibrary) operation(left, right, operator string, orderMatters bool) (string, bool) {
+	res, ok := l.operations[Operation{left, right, operator}]
+	if ok || orderMatters {
+		return res, ok
+	}
+	res, ok = l.operations[Operation{right, left, operator}]
+	return res, ok
+}
+
 func append(filePath, line string) {
 	f, err := os.OpenFile(filePath,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -133,9 +156,11 @@ func append(filePath, line string) {
 	}
 }
 
+// shared so answers piped to stdin are not lost in the buffer of an earlier prompt
+var stdin = bufio.NewReader(os.Stdin)
+
 func prompt(message string) string {
 	fmt.Print(message)
-	reader := bufio.NewReader(os.Stdin)
-	res, _ := reader.ReadString('\n')
+	res, _ := stdin.ReadString('\n')
 	return strings.TrimSpace(res)
 }